@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+func callCommand(args []string) int {
+	flags := flag.NewFlagSet("call", flag.ContinueOnError)
+	logLevel := flags.String("log-level", "warn", "the level of plugin logs to print to stderr")
+	useJSON := flags.Bool("json", false, "read arguments and write the result as JSON instead of HCL expressions")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: tfpolicy-plugin call [options] <plugin> <function> [args...]\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	path, rest, ok := splitPluginArg(flags.Args())
+	if !ok || len(rest) == 0 {
+		flags.Usage()
+		return 1
+	}
+
+	ctx := context.Background()
+	plugin, err := connect(ctx, path, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	defer plugin.Close()
+
+	// Deprecation warnings are printed when the functions are called.
+	functions, _, err := plugin.Functions(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+
+	name := rest[0]
+	fn, ok := functions[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: function %q not found\n", name)
+		return 1
+	}
+
+	var arguments []cty.Value
+	for ix, raw := range rest[1:] {
+		want, err := parameterType(fn, ix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return 1
+		}
+
+		argument, err := parseArgument(raw, want, *useJSON)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid argument %d: %s\n", ix, err)
+			return 1
+		}
+		arguments = append(arguments, argument)
+	}
+
+	result, err := fn.Call(arguments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+
+	if *useJSON {
+		out, err := ctyjson.Marshal(result, result.Type())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return 1
+		}
+		fmt.Println(string(out))
+		return 0
+	}
+
+	fmt.Println(string(hclwrite.TokensForValue(result).Bytes()))
+	return 0
+}
+
+// parameterType returns the type of the parameter that receives the argument
+// at the given index.
+func parameterType(fn function.Function, ix int) (cty.Type, error) {
+	if parameters := fn.Params(); ix < len(parameters) {
+		return parameters[ix].Type, nil
+	}
+	if variadic := fn.VarParam(); variadic != nil {
+		return variadic.Type, nil
+	}
+	return cty.NilType, fmt.Errorf("too many arguments, expected %d", len(fn.Params()))
+}
+
+// parseArgument parses a single argument as either an HCL expression or a JSON
+// value, and converts it into the wanted type.
+func parseArgument(raw string, want cty.Type, useJSON bool) (cty.Value, error) {
+	if useJSON {
+		return ctyjson.Unmarshal([]byte(raw), want)
+	}
+
+	expr, diags := hclsyntax.ParseExpression([]byte(raw), "<argument>", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	return convert.Convert(value, want)
+}
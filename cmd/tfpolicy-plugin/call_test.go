@@ -0,0 +1,110 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty-debug/ctydebug"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+func TestParseArgument(t *testing.T) {
+	tcs := map[string]struct {
+		raw      string
+		want     cty.Type
+		useJSON  bool
+		expected cty.Value
+	}{
+		"string": {
+			raw:      `"hello"`,
+			want:     cty.String,
+			expected: cty.StringVal("hello"),
+		},
+		"converted number": {
+			raw:      `1`,
+			want:     cty.String,
+			expected: cty.StringVal("1"),
+		},
+		"expression": {
+			raw:      `["a", "b"]`,
+			want:     cty.List(cty.String),
+			expected: cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+		"object": {
+			raw:      `{ name = "bucket" }`,
+			want:     cty.Object(map[string]cty.Type{"name": cty.String}),
+			expected: cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("bucket")}),
+		},
+		"dynamic": {
+			raw:      `true`,
+			want:     cty.DynamicPseudoType,
+			expected: cty.True,
+		},
+		"json": {
+			raw:      `{"name": "bucket"}`,
+			want:     cty.Map(cty.String),
+			useJSON:  true,
+			expected: cty.MapVal(map[string]cty.Value{"name": cty.StringVal("bucket")}),
+		},
+		"invalid expression": {
+			raw:  `"unterminated`,
+			want: cty.String,
+		},
+		"variables": {
+			raw:  `var.name`,
+			want: cty.String,
+		},
+		"invalid conversion": {
+			raw:  `"hello"`,
+			want: cty.Number,
+		},
+		"invalid json": {
+			raw:     `hello`,
+			want:    cty.String,
+			useJSON: true,
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			actual, err := parseArgument(tc.raw, tc.want, tc.useJSON)
+			if tc.expected == cty.NilVal {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.expected, actual, ctydebug.CmpOptions); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParameterType(t *testing.T) {
+	fn := function.New(&function.Spec{
+		Params:   []function.Parameter{{Name: "separator", Type: cty.String}},
+		VarParam: &function.Parameter{Name: "values", Type: cty.Number},
+		Type:     function.StaticReturnType(cty.String),
+	})
+
+	for ix, expected := range []cty.Type{cty.String, cty.Number, cty.Number} {
+		if actual, err := parameterType(fn, ix); err != nil || !actual.Equals(expected) {
+			t.Errorf("expected %#v for argument %d, got %#v (%v)", expected, ix, actual, err)
+		}
+	}
+
+	fixed := function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "input", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+	})
+	if _, err := parameterType(fixed, 1); err == nil {
+		t.Errorf("expected an error for too many arguments")
+	}
+}
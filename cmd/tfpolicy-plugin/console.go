@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/chzyer/readline"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/client"
+)
+
+const consoleHelp = `Enter an HCL expression that calls the plugin functions, for example:
+
+    echo("hello")
+
+Type "help" to see this message and the available functions, or "exit" to quit.
+`
+
+func consoleCommand(args []string) int {
+	flags := flag.NewFlagSet("console", flag.ContinueOnError)
+	logLevel := flags.String("log-level", "warn", "the level of plugin logs to print to stderr")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: tfpolicy-plugin console [options] <plugin>\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	path, rest, ok := splitPluginArg(flags.Args())
+	if !ok || len(rest) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	ctx := context.Background()
+	plugin, err := connect(ctx, path, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	defer plugin.Close()
+
+	// Deprecation warnings are printed when the functions are called.
+	functions, _, err := plugin.Functions(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+
+	console := newConsole(functions)
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          "> ",
+		AutoComplete:    console,
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	defer rl.Close()
+
+	console.help(rl.Stdout())
+	for {
+		line, err := rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			continue
+		}
+		if err != nil {
+			// io.EOF when the user presses Ctrl-D.
+			return 0
+		}
+
+		switch line = strings.TrimSpace(line); line {
+		case "":
+			continue
+		case "exit":
+			return 0
+		case "help":
+			console.help(rl.Stdout())
+			continue
+		}
+
+		console.evaluate(rl.Stdout(), rl.Stderr(), line)
+	}
+}
+
+// console evaluates HCL expressions that call the functions of a single plugin.
+type console struct {
+	functions map[string]function.Function
+	names     []string
+	files     map[string]*hcl.File
+}
+
+var _ readline.AutoCompleter = (*console)(nil)
+
+func newConsole(functions map[string]function.Function) *console {
+	var names []string
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &console{
+		functions: functions,
+		names:     names,
+		files:     make(map[string]*hcl.File),
+	}
+}
+
+func (c *console) help(w io.Writer) {
+	fmt.Fprint(w, consoleHelp)
+	fmt.Fprintln(w, "\nFunctions:")
+	for _, name := range c.names {
+		fmt.Fprintf(w, "    %s\n", client.Signature(name, c.functions[name]))
+	}
+	fmt.Fprintln(w)
+}
+
+func (c *console) evaluate(stdout, stderr io.Writer, line string) {
+	filename := fmt.Sprintf("<console-input-%d>", len(c.files))
+	c.files[filename] = &hcl.File{Bytes: []byte(line)}
+	diagnostics := hcl.NewDiagnosticTextWriter(stderr, c.files, 78, false)
+
+	expr, diags := hclsyntax.ParseExpression([]byte(line), filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		diagnostics.WriteDiagnostics(diags)
+		return
+	}
+
+	value, diags := expr.Value(&hcl.EvalContext{
+		Functions: c.functions,
+	})
+	// Warnings are still worth showing alongside a successful result.
+	diagnostics.WriteDiagnostics(diags)
+	if diags.HasErrors() {
+		return
+	}
+
+	if !value.IsWhollyKnown() {
+		fmt.Fprintln(stdout, "(unknown)")
+		return
+	}
+	fmt.Fprintln(stdout, string(hclwrite.TokensForValue(value).Bytes()))
+}
+
+// Do implements readline.AutoCompleter by completing the function name under
+// the cursor.
+func (c *console) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && isNameRune(line[start-1]) {
+		start--
+	}
+	prefix := string(line[start:pos])
+
+	var completions [][]rune
+	for _, name := range c.names {
+		if strings.HasPrefix(name, prefix) {
+			completions = append(completions, []rune(name[len(prefix):]+"("))
+		}
+	}
+	return completions, len(prefix)
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '-' || r == ':' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func testConsole() *console {
+	return newConsole(map[string]function.Function{
+		"upper":         stdlib.UpperFunc,
+		"join":          stdlib.JoinFunc,
+		"aws::arn_join": stdlib.JoinFunc,
+		"unknown": function.New(&function.Spec{
+			Type: function.StaticReturnType(cty.String),
+			Impl: func([]cty.Value, cty.Type) (cty.Value, error) {
+				return cty.UnknownVal(cty.String), nil
+			},
+		}),
+		"fail": function.New(&function.Spec{
+			Type: function.StaticReturnType(cty.String),
+			Impl: func([]cty.Value, cty.Type) (cty.Value, error) {
+				return cty.NilVal, errors.New("failed on purpose")
+			},
+		}),
+	})
+}
+
+func TestConsole_Evaluate(t *testing.T) {
+	tcs := map[string]struct {
+		line   string
+		stdout string
+		stderr string
+	}{
+		"call": {
+			line:   `upper("hello")`,
+			stdout: "\"HELLO\"\n",
+		},
+		"nested calls": {
+			line:   `join(", ", [upper("a"), "b"])`,
+			stdout: "\"A, b\"\n",
+		},
+		"namespaced": {
+			line:   `aws::arn_join(":", ["arn", "aws"])`,
+			stdout: "\"arn:aws\"\n",
+		},
+		"unknown": {
+			line:   `unknown()`,
+			stdout: "(unknown)\n",
+		},
+		"syntax error": {
+			line:   `upper("hello"`,
+			stderr: "Unterminated function call",
+		},
+		"missing function": {
+			line:   `lower("HELLO")`,
+			stderr: "Call to unknown function",
+		},
+		"function error": {
+			line:   `fail()`,
+			stderr: "failed on purpose",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			testConsole().evaluate(&stdout, &stderr, tc.line)
+
+			if stdout.String() != tc.stdout {
+				t.Errorf("expected output %q, got %q", tc.stdout, stdout.String())
+			}
+			if len(tc.stderr) == 0 && stderr.Len() > 0 {
+				t.Errorf("unexpected diagnostics: %s", stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tc.stderr) {
+				t.Errorf("expected diagnostics containing %q, got %q", tc.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestConsole_Do(t *testing.T) {
+	tcs := map[string]struct {
+		line        string
+		pos         int
+		completions []string
+		length      int
+	}{
+		"empty": {
+			completions: []string{"aws::arn_join(", "fail(", "join(", "unknown(", "upper("},
+		},
+		"prefix": {
+			line:        "u",
+			pos:         1,
+			completions: []string{"nknown(", "pper("},
+			length:      1,
+		},
+		"nested": {
+			line:        `join(", ", [up`,
+			pos:         14,
+			completions: []string{"per("},
+			length:      2,
+		},
+		"namespace": {
+			line:        "aws::",
+			pos:         5,
+			completions: []string{"arn_join("},
+			length:      5,
+		},
+		"cursor": {
+			line:        `jo("a")`,
+			pos:         2,
+			completions: []string{"in("},
+			length:      2,
+		},
+		"no match": {
+			line:   "lower",
+			pos:    5,
+			length: 5,
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			completions, length := testConsole().Do([]rune(tc.line), tc.pos)
+
+			var actual []string
+			for _, completion := range completions {
+				actual = append(actual, string(completion))
+			}
+			if diff := cmp.Diff(tc.completions, actual); diff != "" {
+				t.Errorf("unexpected completions (-want +got):\n%s", diff)
+			}
+			if length != tc.length {
+				t.Errorf("expected length %d, got %d", tc.length, length)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/client"
+)
+
+func functionsCommand(args []string) int {
+	flags := flag.NewFlagSet("functions", flag.ContinueOnError)
+	logLevel := flags.String("log-level", "warn", "the level of plugin logs to print to stderr")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: tfpolicy-plugin functions [options] <plugin>\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	path, rest, ok := splitPluginArg(flags.Args())
+	if !ok || len(rest) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	ctx := context.Background()
+	plugin, err := connect(ctx, path, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	defer plugin.Close()
+
+	functions, diags, err := plugin.Functions(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	for _, diagnostic := range diags {
+		printWarning(diagnostic)
+	}
+
+	var names []string
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Println(client.Signature(name, functions[name]))
+		if description := functions[name].Description(); len(description) > 0 {
+			fmt.Printf("    %s\n", description)
+		}
+	}
+	return 0
+}
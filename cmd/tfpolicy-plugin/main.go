@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// tfpolicy-plugin is a developer tool for inspecting and calling the functions
+// published by a Terraform Policy plugin, without needing the full Terraform
+// Policy runtime.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/client"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+const usage = `Usage: tfpolicy-plugin <command> [options] <plugin> [args...]
+
+Commands:
+  functions    List the functions published by the plugin.
+  call         Call a function published by the plugin.
+  console      Start an interactive console for calling the plugin functions.
+
+To connect to a plugin running in debug mode instead of launching one, set the
+TF_POLICY_PLUGIN_REATTACH environment variable printed by the plugin and omit
+the <plugin> argument.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 1
+	}
+
+	switch args[0] {
+	case "functions":
+		return functionsCommand(args[1:])
+	case "call":
+		return callCommand(args[1:])
+	case "console":
+		return consoleCommand(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 1
+	}
+}
+
+// splitPluginArg separates the plugin argument from the remaining arguments.
+// There is no plugin argument when connecting to a plugin in debug mode.
+func splitPluginArg(args []string) (string, []string, bool) {
+	if len(os.Getenv(plugins.ReattachEnvVar)) > 0 {
+		return "", args, true
+	}
+	if len(args) == 0 {
+		return "", nil, false
+	}
+	return args[0], args[1:], true
+}
+
+// connect launches the plugin binary at path and connects to it, or attaches
+// to the plugin running in debug mode if path is empty.
+func connect(ctx context.Context, path string, logLevel string) (*client.Client, error) {
+	level := hclog.LevelFromString(logLevel)
+	if level == hclog.NoLevel {
+		return nil, fmt.Errorf("invalid log level %q", logLevel)
+	}
+
+	logger := client.WithLogger(hclog.New(&hclog.LoggerOptions{
+		Name:   "plugin",
+		Level:  level,
+		Output: os.Stderr,
+	}))
+	warnings := client.WithWarnings(func(_ string, diagnostic *proto.Diagnostic) {
+		printWarning(diagnostic)
+	})
+
+	if len(path) == 0 {
+		var reattach plugins.ReattachConfig
+		if err := json.Unmarshal([]byte(os.Getenv(plugins.ReattachEnvVar)), &reattach); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", plugins.ReattachEnvVar, err)
+		}
+		return client.Attach(ctx, &reattach, logger, warnings)
+	}
+
+	return client.New(ctx, exec.Command(path), logger, warnings)
+}
+
+// printWarning writes a warning diagnostic from the plugin to stderr.
+func printWarning(diagnostic *proto.Diagnostic) {
+	fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", diagnostic.Summary, diagnostic.Detail)
+}
@@ -0,0 +1,410 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package client connects to Terraform Policy plugins from the host side. It is
+// mainly intended for developer tooling and tests, as the Terraform Policy
+// runtime implements its own client.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// Option configures optional behaviour of a Client.
+type Option func(*config)
+
+type config struct {
+	logger      hclog.Logger
+	host        plugins.Host
+	callContext func() context.Context
+	warnings    func(name string, diagnostic *proto.Diagnostic)
+}
+
+// WithLogger sets the logger used for the plugin process, including any logs
+// the plugin writes to stderr. By default, nothing is logged.
+func WithLogger(logger hclog.Logger) Option {
+	return func(config *config) {
+		config.logger = logger
+	}
+}
+
+// WithCallContext sets the function that returns the context for each call the
+// functions returned by Functions make to the plugin, for example one carrying
+// the deadline of the current policy evaluation. By default, calls use
+// context.Background.
+func WithCallContext(callContext func() context.Context) Option {
+	return func(config *config) {
+		config.callContext = callContext
+	}
+}
+
+// WithWarnings sets the function that receives the warnings the plugin returns
+// for each call the functions returned by Functions make, such as deprecation
+// warnings, along with the name of the function. The cty functions can only
+// return errors, so warnings are otherwise dropped.
+func WithWarnings(warnings func(name string, diagnostic *proto.Diagnostic)) Option {
+	return func(config *config) {
+		config.warnings = warnings
+	}
+}
+
+// Client is a connection to a running plugin.
+type Client struct {
+	plugin          *plugin.Client
+	client          proto.PluginClient
+	health          grpc_health_v1.HealthClient
+	protocolVersion int
+	callContext     func() context.Context
+	warnings        func(name string, diagnostic *proto.Diagnostic)
+}
+
+// New launches the plugin executed by cmd, connects to it and calls Setup.
+func New(ctx context.Context, cmd *exec.Cmd, opts ...Option) (*Client, error) {
+	return connect(ctx, &plugin.ClientConfig{
+		Cmd: cmd,
+	}, opts)
+}
+
+// Attach connects to a plugin that is already running in debug mode, using the
+// reattach configuration printed by the plugin, and calls Setup. Closing the
+// client leaves the plugin running.
+func Attach(ctx context.Context, reattach *plugins.ReattachConfig, opts ...Option) (*Client, error) {
+	var addr net.Addr
+	var err error
+	switch reattach.Addr.Network {
+	case "unix":
+		addr, err = net.ResolveUnixAddr("unix", reattach.Addr.String)
+	case "tcp":
+		addr, err = net.ResolveTCPAddr("tcp", reattach.Addr.String)
+	default:
+		err = fmt.Errorf("unsupported network %q", reattach.Addr.Network)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid reattach address: %w", err)
+	}
+
+	return connect(ctx, &plugin.ClientConfig{
+		Reattach: &plugin.ReattachConfig{
+			Protocol:        plugin.Protocol(reattach.Protocol),
+			ProtocolVersion: reattach.ProtocolVersion,
+			Addr:            addr,
+			Pid:             reattach.Pid,
+			Test:            reattach.Test,
+		},
+	}, opts)
+}
+
+func connect(ctx context.Context, clientConfig *plugin.ClientConfig, opts []Option) (*Client, error) {
+	config := &config{
+		logger:      hclog.NewNullLogger(),
+		callContext: context.Background,
+		warnings:    func(string, *proto.Diagnostic) {},
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	clientConfig.HandshakeConfig = plugins.Handshake
+	clientConfig.VersionedPlugins = plugins.ClientPlugins()
+	if clientConfig.Reattach != nil {
+		// go-plugin doesn't negotiate when reattaching, so pick the plugin set
+		// for the version the plugin reported.
+		plugins, ok := clientConfig.VersionedPlugins[clientConfig.Reattach.ProtocolVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported protocol version %d", clientConfig.Reattach.ProtocolVersion)
+		}
+		clientConfig.Plugins = plugins
+	}
+	clientConfig.AllowedProtocols = []plugin.Protocol{plugin.ProtocolGRPC}
+	clientConfig.Logger = config.logger
+	clientConfig.GRPCDialOptions = []grpc.DialOption{
+		grpc.WithUnaryInterceptor(injectTraceContext),
+	}
+	pluginClient := plugin.NewClient(clientConfig)
+
+	rpcClient, err := pluginClient.Client()
+	if err != nil {
+		pluginClient.Kill()
+		return nil, err
+	}
+
+	raw, err := rpcClient.Dispense("plugin")
+	if err != nil {
+		pluginClient.Kill()
+		return nil, err
+	}
+
+	dispensed := raw.(*plugins.PluginClient)
+	client := &Client{
+		plugin:          pluginClient,
+		client:          dispensed,
+		health:          grpc_health_v1.NewHealthClient(rpcClient.(*plugin.GRPCClient).Conn),
+		protocolVersion: pluginClient.NegotiatedVersion(),
+		callContext:     config.callContext,
+		warnings:        config.warnings,
+	}
+	if clientConfig.Reattach != nil {
+		client.protocolVersion = clientConfig.Reattach.ProtocolVersion
+	}
+
+	setup := new(proto.PluginSetupRequest)
+	if config.host != nil {
+		server := &hostServer{host: config.host}
+		_, functions := config.host.(plugins.FunctionHost)
+		setup.ClientCapabilities = &proto.PluginSetupRequest_ClientCapabilities{
+			CallFunction: functions,
+		}
+		setup.HostBrokerId = dispensed.Broker.NextId()
+		go dispensed.Broker.AcceptAndServe(setup.HostBrokerId, func(opts []grpc.ServerOption) *grpc.Server {
+			s := grpc.NewServer(opts...)
+			proto.RegisterHostServer(s, server)
+			return s
+		})
+	}
+
+	if _, err := client.client.Setup(ctx, setup); err != nil {
+		pluginClient.Kill()
+		return nil, fmt.Errorf("failed to set up plugin: %w", err)
+	}
+	return client, nil
+}
+
+// Stop asks the plugin to finish its function executions in progress and run
+// its shutdown hooks, waiting at most until ctx is done. Any failures are
+// returned as a single error.
+func (c *Client) Stop(ctx context.Context) error {
+	response, err := c.client.Stop(ctx, new(proto.StopRequest))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, diagnostic := range response.Diagnostics {
+		if diagnostic.Severity == proto.Diagnostic_ERROR {
+			errs = append(errs, fmt.Errorf("%s: %s", diagnostic.Summary, diagnostic.Detail))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the plugin process, unless the client is attached to a plugin
+// running in debug mode.
+func (c *Client) Close() {
+	c.plugin.Kill()
+}
+
+// Functions returns all the functions published by the plugin, as cty
+// functions that execute within the plugin when called. Aliases are included
+// as additional entries for the same function, and the returned diagnostics
+// warn about deprecated functions. ctx is only used to list the functions, and
+// each call the functions make uses a context from WithCallContext.
+func (c *Client) Functions(ctx context.Context) (map[string]function.Function, []*proto.Diagnostic, error) {
+	response, err := c.client.ListFunctions(ctx, new(proto.ListFunctionsRequest))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	for name := range response.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags []*proto.Diagnostic
+	for _, name := range names {
+		if deprecation := response.Functions[name].Deprecation; deprecation != nil {
+			diags = append(diags, deprecation.Diagnostic(name))
+		}
+	}
+
+	functions := make(map[string]function.Function, len(response.Functions))
+	for name, fn := range response.Functions {
+		var function function.Function
+		var err error
+		if fn.DynamicReturnType {
+			function, err = fn.ToCtyFunctionWithReturnType(c.returnType(name), c.execute(name, fn))
+		} else {
+			function, err = fn.ToCtyFunction(c.execute(name, fn))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid definition for function %s: %w", name, err)
+		}
+		functions[name] = function
+		for _, alias := range fn.Aliases {
+			functions[alias] = function
+		}
+	}
+	return functions, diags, nil
+}
+
+// ProtocolVersion returns the protocol version negotiated with the plugin.
+func (c *Client) ProtocolVersion() int {
+	return c.protocolVersion
+}
+
+// Health returns the status reported by the health service of the plugin,
+// which is serving once the plugin is set up and every readiness check passes.
+func (c *Client) Health(ctx context.Context) (grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	response, err := c.health.Check(ctx, new(grpc_health_v1.HealthCheckRequest))
+	if err != nil {
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN, err
+	}
+	return response.Status, nil
+}
+
+// Metadata returns the name and version the plugin declared, along with the
+// protocol and framework versions and how the plugin binary was built.
+func (c *Client) Metadata(ctx context.Context) (*proto.GetMetadataResponse, error) {
+	return c.client.GetMetadata(ctx, new(proto.GetMetadataRequest))
+}
+
+// returnType asks the plugin for the return type of a function with a dynamic
+// return type.
+func (c *Client) returnType(name string) function.TypeFunc {
+	return func(args []cty.Value) (cty.Type, error) {
+		ctx := c.callContext()
+
+		types := make([][]byte, len(args))
+		for ix, arg := range args {
+			argumentType, err := ctyjson.MarshalType(arg.Type())
+			if err != nil {
+				return cty.NilType, function.NewArgError(ix, err)
+			}
+			types[ix] = argumentType
+		}
+
+		response, err := c.client.GetReturnType(ctx, &proto.GetReturnTypeRequest{
+			Name:          name,
+			ArgumentTypes: types,
+		})
+		if err != nil {
+			return cty.NilType, err
+		}
+		if err := c.diagnostics(name, response.Diagnostics); err != nil {
+			return cty.NilType, err
+		}
+		return ctyjson.UnmarshalType(response.ReturnType)
+	}
+}
+
+func (c *Client) execute(name string, fn *proto.Function) function.ImplFunc {
+	// Any invalid types are reported when the definition is converted into a
+	// cty function, so we can ignore the errors here.
+	var types []cty.Type
+	for _, parameter := range fn.Parameters {
+		param, _ := parameter.ToCtyParameter()
+		types = append(types, param.Type)
+	}
+	var variadic cty.Type
+	if fn.VariadicParameter != nil {
+		param, _ := fn.VariadicParameter.ToCtyParameter()
+		variadic = param.Type
+	}
+
+	return func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		ctx := c.callContext()
+
+		arguments := make([][]byte, len(args))
+		for i, arg := range args {
+			want := variadic
+			if i < len(types) {
+				want = types[i]
+			}
+
+			argument, err := msgpack.Marshal(arg, want)
+			if err != nil {
+				return cty.NilVal, function.NewArgError(i, err)
+			}
+			arguments[i] = argument
+		}
+
+		response, err := c.client.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+			Name:      name,
+			Arguments: arguments,
+		})
+		if err != nil {
+			return cty.NilVal, err
+		}
+		if err := c.diagnostics(name, response.Diagnostics); err != nil {
+			return cty.NilVal, err
+		}
+		return msgpack.Unmarshal(response.Result, retType)
+	}
+}
+
+// diagnostics passes any warnings about a call to the named function to the
+// function set with WithWarnings, and returns the first error, if any.
+func (c *Client) diagnostics(name string, diags []*proto.Diagnostic) error {
+	for _, diagnostic := range diags {
+		if diagnostic.Severity == proto.Diagnostic_ERROR {
+			return fmt.Errorf("%s: %s", diagnostic.Summary, diagnostic.Detail)
+		}
+	}
+	for _, diagnostic := range diags {
+		c.warnings(name, diagnostic)
+	}
+	return nil
+}
+
+// injectTraceContext propagates the span in the context of each request to the
+// plugin, using the globally configured OpenTelemetry propagator.
+func injectTraceContext(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	carrier := make(propagation.MapCarrier)
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for key, value := range carrier {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// Signature renders the signature of the named function using Terraform type
+// syntax, for example "join(separator string, values... string) string". For
+// functions with a dynamic return type, this asks the plugin for the return
+// type of the declared parameter types with the GetReturnType RPC, and renders
+// it as dynamic if that fails.
+func Signature(name string, fn function.Function) string {
+	var types []cty.Type
+	var parameters []string
+	for ix, parameter := range fn.Params() {
+		types = append(types, parameter.Type)
+		parameters = append(parameters, fmt.Sprintf("%s %s", parameterName(ix, parameter), typeexpr.TypeString(parameter.Type)))
+	}
+	if variadic := fn.VarParam(); variadic != nil {
+		parameters = append(parameters, fmt.Sprintf("%s... %s", parameterName(len(fn.Params()), *variadic), typeexpr.TypeString(variadic.Type)))
+	}
+
+	returnType, err := fn.ReturnType(types)
+	if err != nil {
+		returnType = cty.DynamicPseudoType
+	}
+	return fmt.Sprintf("%s(%s) %s", name, strings.Join(parameters, ", "), typeexpr.TypeString(returnType))
+}
+
+func parameterName(ix int, parameter function.Parameter) string {
+	if len(parameter.Name) == 0 {
+		return fmt.Sprintf("arg%d", ix)
+	}
+	return parameter.Name
+}
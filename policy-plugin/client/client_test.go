@@ -0,0 +1,469 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"github.com/zclconf/go-cty-debug/ctydebug"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugintest"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// helperEnvVar makes the test binary act as a plugin, so the tests can launch
+// it as a real plugin process.
+const helperEnvVar = "TF_POLICY_PLUGIN_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnvVar) == "1" {
+		plugins.RegisterFunction("echo", func(s string) (string, error) {
+			return s, nil
+		}, plugins.WithParameters(plugins.Parameter{Name: "input"}))
+		plugins.RegisterFunction("join", func(separator string, values ...string) (string, error) {
+			return strings.Join(values, separator), nil
+		})
+		plugins.RegisterFunction("fail", func() (string, error) {
+			return "", errors.New("failed on purpose")
+		})
+		plugins.RegisterFunction("lookup", func(ctx context.Context, address string) (string, error) {
+			host := plugins.HostFromContext(ctx)
+			resource, ok, err := host.GetResource(ctx, address)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "missing", nil
+			}
+			if err := host.Log(ctx, hclog.Info, "found "+address); err != nil {
+				return "", err
+			}
+			return resource.GetAttr("name").AsString(), nil
+		})
+		plugins.RegisterFunction("call", func(ctx context.Context, name string, arg string) (string, error) {
+			result, err := plugins.FunctionCallerFromContext(ctx).CallFunction(ctx, name, cty.StringVal(arg))
+			if err != nil {
+				return "", err
+			}
+			return result.AsString(), nil
+		})
+		plugins.RegisterFunction("wrap", func(value cty.Value) (cty.Value, error) {
+			return cty.ListVal([]cty.Value{value}), nil
+		}, plugins.WithReturnType(func(args []cty.Type) (cty.Type, error) {
+			return cty.List(args[0]), nil
+		}))
+		plugins.RegisterFunction("shout", func(s string) (string, error) {
+			return strings.ToUpper(s), nil
+		}, plugins.WithDeprecation(plugins.Deprecation{Replacement: "echo"}))
+		plugins.Serve(plugins.WithName("helper"), plugins.WithVersion("1.2.3"))
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func helperCommand() *exec.Cmd {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), helperEnvVar+"=1")
+	return cmd
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := New(ctx, helperCommand())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer client.Close()
+
+	if version := client.ProtocolVersion(); version != plugins.LatestProtocolVersion {
+		t.Errorf("expected protocol version %d to be negotiated, got %d", plugins.LatestProtocolVersion, version)
+	}
+
+	functions, _, err := client.Functions(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	signatures := map[string]string{
+		"echo": "echo(input string) string",
+		"join": "join(arg0 string, arg1... string) string",
+		"fail": "fail() string",
+	}
+	for name, expected := range signatures {
+		fn, ok := functions[name]
+		if !ok {
+			t.Fatalf("expected function %s", name)
+		}
+		if signature := Signature(name, fn); signature != expected {
+			t.Errorf("expected signature %q but got %q", expected, signature)
+		}
+	}
+
+	result, err := functions["join"].Call([]cty.Value{cty.StringVal(", "), cty.StringVal("a"), cty.StringVal("b")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(cty.StringVal("a, b"), result, ctydebug.CmpOptions); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+
+	if _, err := functions["fail"].Call(nil); err == nil || !strings.Contains(err.Error(), "failed on purpose") {
+		t.Errorf("expected error from fail function, got %v", err)
+	}
+
+	if health, err := client.Health(ctx); err != nil || health != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("expected the plugin to be serving after setup, got %s (%v)", health, err)
+	}
+
+	if err := client.Stop(ctx); err != nil {
+		t.Fatalf("unexpected error stopping the plugin: %s", err)
+	}
+	if _, err := functions["echo"].Call([]cty.Value{cty.StringVal("hello")}); err == nil || !strings.Contains(err.Error(), "shutting down") {
+		t.Errorf("expected executions to be rejected after stopping, got %v", err)
+	}
+	if health, err := client.Health(ctx); err != nil || health != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected the plugin to stop serving after stopping, got %s (%v)", health, err)
+	}
+
+	metadata, err := client.Metadata(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if metadata.Name != "helper" || metadata.Version != "1.2.3" || metadata.ProtocolVersion != plugins.LatestProtocolVersion {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+	if metadata.BuildInfo == nil || len(metadata.BuildInfo.GoVersion) == 0 {
+		t.Errorf("expected build info, got %v", metadata.BuildInfo)
+	}
+}
+
+func TestDynamicReturnType(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := New(ctx, helperCommand())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer client.Close()
+
+	functions, _, err := client.Functions(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, value := range []cty.Value{
+		cty.StringVal("hello"),
+		cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("bucket")}),
+	} {
+		returnType, err := functions["wrap"].ReturnType([]cty.Type{value.Type()})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !returnType.Equals(cty.List(value.Type())) {
+			t.Errorf("unexpected return type: %#v", returnType)
+		}
+
+		result, err := functions["wrap"].Call([]cty.Value{value})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if diff := cmp.Diff(cty.ListVal([]cty.Value{value}), result, ctydebug.CmpOptions); diff != "" {
+			t.Errorf("unexpected result (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	ctx := context.Background()
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	var callContext context.Context
+	client, err := New(ctx, helperCommand(), WithCallContext(func() context.Context {
+		if callContext != nil {
+			return callContext
+		}
+		return ctx
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer client.Close()
+
+	listCtx, cancelList := context.WithCancel(ctx)
+	functions, _, err := client.Functions(listCtx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cancelList()
+
+	// Cancelling the context used to list the functions doesn't affect calls.
+	if _, err := functions["echo"].Call([]cty.Value{cty.StringVal("hello")}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	callContext = cancelled
+	if _, err := functions["echo"].Call([]cty.Value{cty.StringVal("hello")}); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected the call to use the cancelled context, got %v", err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	ctx := context.Background()
+
+	var warnings []string
+	client, err := New(ctx, helperCommand(), WithWarnings(func(name string, diagnostic *proto.Diagnostic) {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", name, diagnostic.Summary))
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer client.Close()
+
+	functions, diags, err := client.Functions(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(diags) != 1 || diags[0].Severity != proto.Diagnostic_WARNING || !strings.Contains(diags[0].Detail, `"shout" is deprecated`) {
+		t.Errorf("expected a deprecation warning for shout, got %v", diags)
+	}
+
+	if _, err := functions["echo"].Call([]cty.Value{cty.StringVal("hello")}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := functions["shout"].Call([]cty.Value{cty.StringVal("hello")}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff([]string{"shout: Deprecated function"}, warnings); diff != "" {
+		t.Errorf("unexpected warnings (-want +got):\n%s", diff)
+	}
+}
+
+func TestHost(t *testing.T) {
+	ctx := context.Background()
+
+	host := &plugintest.FakeHost{
+		Resources: map[string]cty.Value{
+			"aws_s3_bucket.logs": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("my-logs"),
+			}),
+		},
+		Functions: map[string]function.Function{
+			"upper": stdlib.UpperFunc,
+		},
+	}
+
+	client, err := New(ctx, helperCommand(), WithHost(host))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer client.Close()
+
+	functions, _, err := client.Functions(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := functions["lookup"].Call([]cty.Value{cty.StringVal("aws_s3_bucket.logs")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(cty.StringVal("my-logs"), result, ctydebug.CmpOptions); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+
+	result, err = functions["lookup"].Call([]cty.Value{cty.StringVal("aws_s3_bucket.missing")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(cty.StringVal("missing"), result, ctydebug.CmpOptions); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+
+	// Functions registered in the plugin are called first, and otherwise the
+	// host's functions.
+	for name, expected := range map[string]string{"echo": "hello", "upper": "HELLO"} {
+		result, err := functions["call"].Call([]cty.Value{cty.StringVal(name), cty.StringVal("hello")})
+		if err != nil {
+			t.Fatalf("unexpected error calling %s: %s", name, err)
+		}
+		if diff := cmp.Diff(cty.StringVal(expected), result, ctydebug.CmpOptions); diff != "" {
+			t.Errorf("unexpected result calling %s (-want +got):\n%s", name, diff)
+		}
+	}
+	if _, err := functions["call"].Call([]cty.Value{cty.StringVal("missing"), cty.StringVal("hello")}); err == nil || !strings.Contains(err.Error(), "function missing not found") {
+		t.Errorf("expected the host to report missing functions, got %v", err)
+	}
+
+	logs := host.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log entry, got %v", logs)
+	}
+	if logs[0].Level != hclog.Info || logs[0].Message != "found aws_s3_bucket.logs" || logs[0].Call.Function != "lookup" || len(logs[0].Call.ID) == 0 {
+		t.Errorf("unexpected log entry: %v", logs[0])
+	}
+}
+
+func TestHost_NoHost(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := New(ctx, helperCommand())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer client.Close()
+
+	functions, _, err := client.Functions(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := functions["lookup"].Call([]cty.Value{cty.StringVal("aws_s3_bucket.logs")}); err == nil || !strings.Contains(err.Error(), plugins.ErrNoHost.Error()) {
+		t.Errorf("expected ErrNoHost, got %v", err)
+	}
+	if _, err := functions["call"].Call([]cty.Value{cty.StringVal("upper"), cty.StringVal("hello")}); err == nil || !strings.Contains(err.Error(), "function upper not found") {
+		t.Errorf("expected host functions to be unavailable, got %v", err)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	plugins.RegisterFunction("debug_echo", func(s string) (string, error) {
+		return s, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- plugins.ServeDebug(ctx, writer)
+		writer.Close()
+	}()
+
+	var reattach plugins.ReattachConfig
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, plugins.ReattachEnvVar+"=") {
+			raw := strings.Trim(strings.TrimPrefix(line, plugins.ReattachEnvVar+"="), "'")
+			if err := json.Unmarshal([]byte(raw), &reattach); err != nil {
+				t.Fatalf("invalid reattach config: %s", err)
+			}
+			break
+		}
+	}
+	go io.Copy(io.Discard, reader)
+
+	if reattach.Pid != os.Getpid() || reattach.Protocol != "grpc" {
+		t.Fatalf("unexpected reattach config: %+v", reattach)
+	}
+
+	// Connect twice, to make sure closing the first client leaves the plugin
+	// running.
+	for i := 0; i < 2; i++ {
+		client, err := Attach(ctx, &reattach)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		functions, _, err := client.Functions(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result, err := functions["debug_echo"].Call([]cty.Value{cty.StringVal("hello")})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !result.RawEquals(cty.StringVal("hello")) {
+			t.Fatalf("unexpected result: %#v", result)
+		}
+		client.Close()
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestProtocolVersions(t *testing.T) {
+	plugins.RegisterFunction("versions_echo", func(s string) (string, error) {
+		return s, nil
+	})
+	plugins.RegisterFunction("versions_fail", func() (string, error) {
+		return "", errors.New("failed on purpose")
+	})
+
+	// A host that predates protocol negotiation only knows the handshake
+	// version, and should be served the oldest protocol.
+	legacy := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig: plugins.Handshake,
+		Plugins: plugin.PluginSet{
+			"plugin": new(plugins.PluginServer),
+		},
+		Cmd:              helperCommand(),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+		Logger:           hclog.NewNullLogger(),
+	})
+	defer legacy.Kill()
+
+	if _, err := legacy.Client(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if version := legacy.NegotiatedVersion(); version != plugins.ProtocolVersion1 {
+		t.Errorf("expected legacy host to be served version %d, got %d", plugins.ProtocolVersion1, version)
+	}
+
+	// The client should handle every version the plugin can serve.
+	for version, set := range plugins.ClientPlugins() {
+		conn, _ := plugin.TestPluginGRPCConn(t, false, set)
+		raw, err := conn.Dispense("plugin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &Client{
+			client:          raw.(proto.PluginClient),
+			protocolVersion: version,
+			callContext:     context.Background,
+		}
+
+		functions, _, err := client.Functions(context.Background())
+		if err != nil {
+			t.Fatalf("v%d: unexpected error: %s", version, err)
+		}
+
+		result, err := functions["versions_echo"].Call([]cty.Value{cty.StringVal("hello")})
+		if err != nil {
+			t.Errorf("v%d: unexpected error: %s", version, err)
+		} else if !result.RawEquals(cty.StringVal("hello")) {
+			t.Errorf("v%d: unexpected result: %#v", version, result)
+		}
+
+		if _, err := functions["versions_fail"].Call(nil); err == nil || !strings.Contains(err.Error(), "failed on purpose") {
+			t.Errorf("v%d: expected error from fail function, got %v", version, err)
+		}
+		conn.Close()
+	}
+}
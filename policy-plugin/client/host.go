@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+var (
+	_ proto.HostServer = (*hostServer)(nil)
+)
+
+// WithHost serves host to the plugin, so functions can query it through
+// plugins.HostFromContext. If host also implements plugins.FunctionHost, the
+// plugin's functions can call the functions it provides through
+// plugins.FunctionCallerFromContext. By default, functions can't call the host.
+func WithHost(host plugins.Host) Option {
+	return func(config *config) {
+		config.host = host
+	}
+}
+
+// hostServer serves a plugins.Host to the plugin through the go-plugin broker.
+type hostServer struct {
+	host plugins.Host
+}
+
+func (h *hostServer) GetResource(ctx context.Context, request *proto.GetResourceRequest) (*proto.GetResourceResponse, error) {
+	value, found, err := h.host.GetResource(ctx, request.Address)
+	if err != nil || !found {
+		return &proto.GetResourceResponse{}, err
+	}
+
+	valueType, encoded, err := encodeHostValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.GetResourceResponse{
+		Found: true,
+		Type:  valueType,
+		Value: encoded,
+	}, nil
+}
+
+func (h *hostServer) GetVariable(ctx context.Context, request *proto.GetVariableRequest) (*proto.GetVariableResponse, error) {
+	value, found, err := h.host.GetVariable(ctx, request.Name)
+	if err != nil || !found {
+		return &proto.GetVariableResponse{}, err
+	}
+
+	valueType, encoded, err := encodeHostValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.GetVariableResponse{
+		Found: true,
+		Type:  valueType,
+		Value: encoded,
+	}, nil
+}
+
+func (h *hostServer) Log(ctx context.Context, request *proto.HostLogRequest) (*proto.HostLogResponse, error) {
+	ctx = plugins.ContextWithCall(ctx, plugins.Call{
+		Function: request.Function,
+		ID:       request.CallId,
+	})
+	if err := h.host.Log(ctx, hclog.LevelFromString(request.Level), request.Message); err != nil {
+		return nil, err
+	}
+	return new(proto.HostLogResponse), nil
+}
+
+func (h *hostServer) CallFunction(ctx context.Context, request *proto.CallFunctionRequest) (*proto.CallFunctionResponse, error) {
+	host, ok := h.host.(plugins.FunctionHost)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the host doesn't provide functions")
+	}
+
+	ctx = plugins.ContextWithCall(ctx, plugins.Call{
+		Function: request.Function,
+		ID:       request.CallId,
+	})
+
+	var args []cty.Value
+	for ix, arg := range request.Arguments {
+		valueType, err := ctyjson.UnmarshalType(arg.Type)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid type for argument %d: %s", ix, err)
+		}
+		value, err := msgpack.Unmarshal(arg.Value, valueType)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid value for argument %d: %s", ix, err)
+		}
+		args = append(args, value)
+	}
+
+	result, err := host.CallFunction(ctx, request.Name, args...)
+	if err != nil {
+		return &proto.CallFunctionResponse{
+			Diagnostics: []*proto.Diagnostic{
+				{
+					Severity: proto.Diagnostic_ERROR,
+					Summary:  "Error in function call",
+					Detail:   err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	valueType, encoded, err := encodeHostValue(result)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.CallFunctionResponse{
+		Result: &proto.DynamicValue{
+			Type:  valueType,
+			Value: encoded,
+		},
+	}, nil
+}
+
+func encodeHostValue(value cty.Value) ([]byte, []byte, error) {
+	valueType, err := ctyjson.MarshalType(value.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encoded, err := msgpack.Marshal(value, value.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+	return valueType, encoded, nil
+}
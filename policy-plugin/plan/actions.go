@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plan
+
+// Action classifies the actions of a change.
+type Action string
+
+const (
+	NoOp    Action = "no-op"
+	Create  Action = "create"
+	Read    Action = "read"
+	Update  Action = "update"
+	Delete  Action = "delete"
+	Forget  Action = "forget"
+	Replace Action = "replace"
+
+	// Unknown is the action of changes with actions this package doesn't
+	// recognise.
+	Unknown Action = "unknown"
+)
+
+// Action classifies the actions of the change. Resources that are deleted and
+// created again, in either order, are replaced.
+func (c Change) Action() Action {
+	switch len(c.Actions) {
+	case 1:
+		switch action := Action(c.Actions[0]); action {
+		case NoOp, Create, Read, Update, Delete, Forget:
+			return action
+		}
+	case 2:
+		first, second := Action(c.Actions[0]), Action(c.Actions[1])
+		if (first == Delete && second == Create) || (first == Create && second == Delete) {
+			return Replace
+		}
+	}
+	return Unknown
+}
+
+// Creates returns true if the change creates the resource, including when it
+// replaces it.
+func (c Change) Creates() bool {
+	action := c.Action()
+	return action == Create || action == Replace
+}
+
+// Deletes returns true if the change deletes the resource, including when it
+// replaces it.
+func (c Change) Deletes() bool {
+	action := c.Action()
+	return action == Delete || action == Replace
+}
+
+// CreateBeforeDestroy returns true if the change replaces the resource by
+// creating the new object before deleting the old one.
+func (c Change) CreateBeforeDestroy() bool {
+	return c.Action() == Replace && Action(c.Actions[0]) == Create
+}
@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plan
+
+import (
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins/convert"
+)
+
+// Lookup returns the attribute of value at path, and false if there is no such
+// attribute. Paths use the Terraform syntax parsed by convert.ParsePath, as in
+// `ingress[0].cidr_blocks` or `tags["team.name"]`, and attribute names also
+// look up the keys of maps. An empty path returns value itself.
+func Lookup(value cty.Value, path convert.Path) (cty.Value, bool) {
+	result, err := path.Apply(value)
+	if err != nil {
+		return cty.NilVal, false
+	}
+	return result, true
+}
+
+// flagged returns true if the attribute of flags at path, or any attribute
+// containing it, is true. Terraform uses such values to mark which attributes
+// are unknown or sensitive.
+func flagged(flags cty.Value, path convert.Path) bool {
+	// A step of a convert.Path can hold both an attribute and an index, so
+	// the containing attributes are found from the steps of the cty path.
+	steps, err := path.ToCtyPath()
+	if err != nil {
+		return false
+	}
+
+	for ix := 0; ix <= len(steps); ix++ {
+		prefix, err := convert.FromCtyPath(steps[:ix])
+		if err != nil {
+			return false
+		}
+		value, ok := Lookup(flags, prefix)
+		if !ok {
+			return false
+		}
+		if isTrue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTrue(value cty.Value) bool {
+	return value.Type() == cty.Bool && value.IsKnown() && !value.IsNull() && value.True()
+}
@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package plan provides Go types for the resource changes of a Terraform plan
+// in the JSON output format, for use as the parameters of plugin functions.
+package plan
+
+import (
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins/convert"
+)
+
+// ResourceChange is an entry of the resource_changes of a plan. It can be used
+// as a parameter of functions registered with plugins.RegisterFunction, and
+// any attributes of the entry it doesn't declare are ignored.
+type ResourceChange struct {
+	Address      string `cty:"address"`
+	Mode         string `cty:"mode"`
+	Type         string `cty:"type"`
+	Name         string `cty:"name"`
+	ProviderName string `cty:"provider_name"`
+	Change       Change `cty:"change"`
+}
+
+// Change describes how a resource changes. Before is null for resources being
+// created, and After is null for resources being deleted.
+type Change struct {
+	Actions []string `cty:"actions"`
+
+	Before cty.Value `cty:"before"`
+	After  cty.Value `cty:"after"`
+
+	// AfterUnknown mirrors After, with true wherever the value is only known
+	// after apply.
+	AfterUnknown cty.Value `cty:"after_unknown"`
+
+	// BeforeSensitive and AfterSensitive mirror Before and After, with true
+	// wherever the value is sensitive.
+	BeforeSensitive cty.Value `cty:"before_sensitive"`
+	AfterSensitive  cty.Value `cty:"after_sensitive"`
+}
+
+// GetBefore returns the attribute of Before at path, and false if there is no
+// such attribute.
+func (c Change) GetBefore(path convert.Path) (cty.Value, bool) {
+	return Lookup(c.Before, path)
+}
+
+// GetAfter returns the attribute of After at path, and false if there is no
+// such attribute. Attributes only known after apply are null in After, so use
+// IsUnknown to tell them apart from attributes that are actually null.
+func (c Change) GetAfter(path convert.Path) (cty.Value, bool) {
+	return Lookup(c.After, path)
+}
+
+// IsUnknown returns true if the attribute of After at path, or any attribute
+// containing it, is only known after apply.
+func (c Change) IsUnknown(path convert.Path) bool {
+	return flagged(c.AfterUnknown, path)
+}
+
+// IsSensitive returns true if the attribute of After at path, or any attribute
+// containing it, is sensitive.
+func (c Change) IsSensitive(path convert.Path) bool {
+	return flagged(c.AfterSensitive, path)
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins/convert"
+)
+
+const bucketChange = `{
+  "address": "aws_s3_bucket.logs",
+  "mode": "managed",
+  "type": "aws_s3_bucket",
+  "name": "logs",
+  "provider_name": "registry.terraform.io/hashicorp/aws",
+  "change": {
+    "actions": ["create"],
+    "before": null,
+    "after": {
+      "bucket": "logs",
+      "tags": {"Owner": "platform"},
+      "grants": [{"permissions": ["READ"], "uri": "http://acs.amazonaws.com/groups/global/AllUsers"}],
+      "arn": null
+    },
+    "after_unknown": {"arn": true, "grants": [{"id": true}], "versioning": true},
+    "before_sensitive": false,
+    "after_sensitive": {"tags": {"Owner": true}}
+  },
+  "action_reason": "ignored by the types"
+}`
+
+func decode(t *testing.T, src string) cty.Value {
+	t.Helper()
+
+	ty, err := ctyjson.ImpliedType([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := ctyjson.Unmarshal([]byte(src), ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func mustParsePath(t *testing.T, path string) convert.Path {
+	t.Helper()
+	parsed, err := convert.ParsePath(path)
+	if err != nil {
+		t.Fatalf("invalid path %s: %s", path, err)
+	}
+	return parsed
+}
+
+func TestLookup(t *testing.T) {
+	value := cty.ObjectVal(map[string]cty.Value{
+		"tags": cty.MapVal(map[string]cty.Value{
+			"team.name": cty.StringVal("platform"),
+			"0":         cty.StringVal("zero"),
+		}),
+	})
+
+	tcs := map[string]cty.Value{
+		`tags["team.name"]`: cty.StringVal("platform"),
+		`tags["0"]`:         cty.StringVal("zero"),
+		`tags.missing`:      cty.NilVal,
+		`tags[0]`:           cty.NilVal,
+		`tags[*]`:           cty.NilVal,
+	}
+	for path, expected := range tcs {
+		actual, ok := Lookup(value, mustParsePath(t, path))
+		if ok != (expected != cty.NilVal) || (ok && !actual.RawEquals(expected)) {
+			t.Errorf("expected %#v at %s, got %#v (%t)", expected, path, actual, ok)
+		}
+	}
+}
+
+func TestResourceChange(t *testing.T) {
+	grantURI := mustParsePath(t, "grants[0].uri")
+	plugins.RegisterFunction("plan_public_grant", func(change ResourceChange) (bool, error) {
+		uri, ok := change.Change.GetAfter(grantURI)
+		return ok && uri.AsString() == "http://acs.amazonaws.com/groups/global/AllUsers", nil
+	})
+
+	value := decode(t, bucketChange)
+
+	// The caller converts the JSON value to the type of the parameter, just
+	// like hosts do.
+	ctx := context.Background()
+	result, err := plugins.FunctionCallerFromContext(ctx).CallFunction(ctx, "plan_public_grant", value)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.False() {
+		t.Errorf("expected the grant to be public")
+	}
+}
+
+func TestChange(t *testing.T) {
+	value := decode(t, bucketChange)
+	change := Change{
+		Actions:         []string{"create"},
+		Before:          value.GetAttr("change").GetAttr("before"),
+		After:           value.GetAttr("change").GetAttr("after"),
+		AfterUnknown:    value.GetAttr("change").GetAttr("after_unknown"),
+		BeforeSensitive: value.GetAttr("change").GetAttr("before_sensitive"),
+		AfterSensitive:  value.GetAttr("change").GetAttr("after_sensitive"),
+	}
+
+	if owner, ok := change.GetAfter(mustParsePath(t, "tags.Owner")); !ok || owner.AsString() != "platform" {
+		t.Errorf("unexpected owner tag: %#v", owner)
+	}
+	if _, ok := change.GetAfter(mustParsePath(t, "grants[1].uri")); ok {
+		t.Errorf("expected no second grant")
+	}
+	if _, ok := change.GetBefore(mustParsePath(t, "bucket")); ok {
+		t.Errorf("expected no attributes before creation")
+	}
+
+	unknown := map[string]bool{
+		"arn":                   true,
+		"bucket":                false,
+		"grants[0].id":          true,
+		"grants[0].uri":         false,
+		"versioning[0].enabled": true,
+		"tags.Owner":            false,
+	}
+	for path, expected := range unknown {
+		if actual := change.IsUnknown(mustParsePath(t, path)); actual != expected {
+			t.Errorf("expected IsUnknown(%q) to be %t", path, expected)
+		}
+	}
+
+	if !change.IsSensitive(mustParsePath(t, "tags.Owner")) || change.IsSensitive(mustParsePath(t, "bucket")) {
+		t.Errorf("unexpected sensitive attributes")
+	}
+}
+
+func TestChange_Action(t *testing.T) {
+	tcs := map[string]struct {
+		actions             []string
+		action              Action
+		creates, deletes    bool
+		createBeforeDestroy bool
+	}{
+		"no-op":                  {actions: []string{"no-op"}, action: NoOp},
+		"create":                 {actions: []string{"create"}, action: Create, creates: true},
+		"read":                   {actions: []string{"read"}, action: Read},
+		"update":                 {actions: []string{"update"}, action: Update},
+		"delete":                 {actions: []string{"delete"}, action: Delete, deletes: true},
+		"forget":                 {actions: []string{"forget"}, action: Forget},
+		"replace":                {actions: []string{"delete", "create"}, action: Replace, creates: true, deletes: true},
+		"create before destroy":  {actions: []string{"create", "delete"}, action: Replace, creates: true, deletes: true, createBeforeDestroy: true},
+		"unrecognised":           {actions: []string{"explode"}, action: Unknown},
+		"unrecognised sequences": {actions: []string{"update", "delete"}, action: Unknown},
+		"empty":                  {action: Unknown},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			change := Change{Actions: tc.actions}
+			if action := change.Action(); action != tc.action {
+				t.Errorf("expected %s, got %s", tc.action, action)
+			}
+			if change.Creates() != tc.creates || change.Deletes() != tc.deletes || change.CreateBeforeDestroy() != tc.createBeforeDestroy {
+				t.Errorf("unexpected classification: creates %t, deletes %t, create before destroy %t", change.Creates(), change.Deletes(), change.CreateBeforeDestroy())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"container/list"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures the memoisation of function results enabled by
+// WithCache.
+type CacheOptions struct {
+	// MaxEntries is the maximum number of results kept in the cache. Zero
+	// means there is no limit.
+	MaxEntries int
+
+	// MaxBytes is the maximum combined size of the cached keys and results.
+	// Zero means there is no limit.
+	MaxBytes int
+
+	// TTL is how long a cached result remains valid. Zero means results never
+	// expire.
+	TTL time.Duration
+}
+
+// CacheStats reports the usage of a function cache.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Bytes   int
+}
+
+// cache is a least recently used cache of msgpack encoded function results,
+// keyed by the function name and msgpack encoded arguments.
+type cache struct {
+	options CacheOptions
+	now     func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	bytes   int
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	key     string
+	result  []byte
+	expires time.Time
+}
+
+func newCache(options CacheOptions) *cache {
+	return &cache{
+		options: options,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// cacheKey builds the key for a call to the named function with the given
+// msgpack encoded arguments. Each argument is length prefixed so that
+// different splits of the same bytes never collide.
+func cacheKey(name string, arguments [][]byte) string {
+	var key strings.Builder
+	key.WriteString(name)
+	key.WriteByte(0)
+
+	var length [binary.MaxVarintLen64]byte
+	for _, argument := range arguments {
+		n := binary.PutUvarint(length[:], uint64(len(argument)))
+		key.Write(length[:n])
+		key.Write(argument)
+	}
+	return key.String()
+}
+
+func (c *cache) get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(element)
+		c.misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits++
+	return entry.result, true
+}
+
+func (c *cache) put(key string, result []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	size := len(key) + len(result)
+	if c.options.MaxBytes > 0 && size > c.options.MaxBytes {
+		// This result could never fit, so don't evict everything else trying.
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	entry := &cacheEntry{
+		key:    key,
+		result: result,
+	}
+	if c.options.TTL > 0 {
+		entry.expires = c.now().Add(c.options.TTL)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.bytes += size
+
+	for c.full() {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *cache) stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return CacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: len(c.entries),
+		Bytes:   c.bytes,
+	}
+}
+
+func (c *cache) full() bool {
+	if c.options.MaxEntries > 0 && len(c.entries) > c.options.MaxEntries {
+		return true
+	}
+	if c.options.MaxBytes > 0 && c.bytes > c.options.MaxBytes {
+		return true
+	}
+	return false
+}
+
+func (c *cache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= len(entry.key) + len(entry.result)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestCache(t *testing.T) {
+	t.Run("maxEntries", func(t *testing.T) {
+		c := newCache(CacheOptions{MaxEntries: 2})
+		c.put("a", []byte("a"))
+		c.put("b", []byte("b"))
+		c.get("a") // a is now the most recently used
+		c.put("c", []byte("c"))
+
+		if _, ok := c.get("b"); ok {
+			t.Errorf("expected b to have been evicted")
+		}
+		if _, ok := c.get("a"); !ok {
+			t.Errorf("expected a to be cached")
+		}
+		if _, ok := c.get("c"); !ok {
+			t.Errorf("expected c to be cached")
+		}
+	})
+
+	t.Run("maxBytes", func(t *testing.T) {
+		c := newCache(CacheOptions{MaxBytes: 8})
+		c.put("a", []byte("aaa"))
+		c.put("b", []byte("bbb"))
+		c.put("c", []byte("ccc"))
+		c.put("d", []byte("way too large to fit"))
+
+		if stats := c.stats(); stats.Entries != 2 || stats.Bytes != 8 {
+			t.Errorf("expected 2 entries and 8 bytes, got %d and %d", stats.Entries, stats.Bytes)
+		}
+		if _, ok := c.get("a"); ok {
+			t.Errorf("expected a to have been evicted")
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		now := time.Now()
+		c := newCache(CacheOptions{TTL: time.Minute})
+		c.now = func() time.Time { return now }
+
+		c.put("a", []byte("a"))
+		if _, ok := c.get("a"); !ok {
+			t.Errorf("expected a to be cached")
+		}
+
+		now = now.Add(time.Minute)
+		if _, ok := c.get("a"); ok {
+			t.Errorf("expected a to have expired")
+		}
+
+		if stats := c.stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 0 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+}
+
+func TestExecuteFunction_Cache(t *testing.T) {
+	var calls int
+	RegisterFunction("cached", func(s string) (string, error) {
+		calls++
+		return s, nil
+	}, WithCache(CacheOptions{MaxEntries: 10}))
+
+	argument, err := msgpack.Marshal(cty.StringVal("hello"), cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := new(GrpcServer)
+	for i := 0; i < 3; i++ {
+		response, err := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+			Name:      "cached",
+			Arguments: [][]byte{argument},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result, err := msgpack.Unmarshal(response.Result, cty.String)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.RawEquals(cty.StringVal("hello")) {
+			t.Fatalf("unexpected result: %#v", result)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected the function to be called once, but was called %d times", calls)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// maxCallDepth limits how deeply functions can call other functions through a
+// FunctionCaller, so a function that calls itself fails instead of exhausting
+// the stack of the plugin.
+const maxCallDepth = 64
+
+var (
+	_ FunctionCaller = functionCaller{}
+	_ FunctionHost   = (*grpcFunctionHost)(nil)
+)
+
+// FunctionCaller calls functions by name with cty values.
+type FunctionCaller interface {
+	// CallFunction calls the named function, and returns an error if there is
+	// no such function or the call fails.
+	CallFunction(ctx context.Context, name string, args ...cty.Value) (cty.Value, error)
+}
+
+// FunctionHost is implemented by hosts that provide functions to plugins, such
+// as the built-in functions of the policy runtime or functions from other
+// plugins.
+type FunctionHost interface {
+	Host
+	FunctionCaller
+}
+
+// FunctionCallerFromContext returns a caller that functions can use to call
+// other functions. Functions registered in the plugin are called first, with
+// the arguments converted to the types of their parameters, and otherwise the
+// call is passed to the host if it provides functions.
+//
+// Local functions are called directly, so their results aren't cached and the
+// calls aren't measured by the metrics. Each call gets its own Call and logger,
+// and calls nested more than 64 deep fail.
+func FunctionCallerFromContext(ctx context.Context) FunctionCaller {
+	return functionCaller{
+		host: HostFromContext(ctx),
+	}
+}
+
+type functionCaller struct {
+	host Host
+}
+
+type callDepthKey struct{}
+
+func (c functionCaller) CallFunction(ctx context.Context, name string, args ...cty.Value) (cty.Value, error) {
+	depth, _ := ctx.Value(callDepthKey{}).(int)
+	if depth >= maxCallDepth {
+		return cty.NilVal, fmt.Errorf("calling function %s exceeds the maximum call depth of %d", name, maxCallDepth)
+	}
+	ctx = context.WithValue(ctx, callDepthKey{}, depth+1)
+
+	if fn, ok := lookupFunction(name); ok {
+		args, err := convertArguments(fn.Function, args)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid arguments for function %s: %w", name, err)
+		}
+
+		parent, _ := CallFromContext(ctx)
+		call := Call{Function: fn.name, ID: newCallID()}
+		ctx = ContextWithLogger(ctx, Logger(ctx).With("function", call.Function, "call_id", call.ID, "parent_call_id", parent.ID))
+		return fn.call(ContextWithCall(ctx, call), args)
+	}
+	if host, ok := c.host.(FunctionHost); ok {
+		return host.CallFunction(ctx, name, args...)
+	}
+	return cty.NilVal, fmt.Errorf("function %s not found", name)
+}
+
+// convertArguments converts args to the types of the parameters of fn, leaving
+// any arguments beyond its parameters for the function to reject.
+func convertArguments(fn function.Function, args []cty.Value) ([]cty.Value, error) {
+	params := fn.Params()
+	variadic := fn.VarParam()
+
+	converted := make([]cty.Value, len(args))
+	for ix, arg := range args {
+		var param *function.Parameter
+		switch {
+		case ix < len(params):
+			param = &params[ix]
+		case variadic != nil:
+			param = variadic
+		default:
+			converted[ix] = arg
+			continue
+		}
+
+		value, err := ctyconvert.Convert(arg, param.Type)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", ix, err)
+		}
+		converted[ix] = value
+	}
+	return converted, nil
+}
+
+// grpcFunctionHost is the host of clients that advertise they serve the
+// CallFunction RPC.
+type grpcFunctionHost struct {
+	*grpcHost
+}
+
+func (h *grpcFunctionHost) CallFunction(ctx context.Context, name string, args ...cty.Value) (cty.Value, error) {
+	call, _ := CallFromContext(ctx)
+	request := &proto.CallFunctionRequest{
+		Name:     name,
+		Function: call.Function,
+		CallId:   call.ID,
+	}
+	for ix, arg := range args {
+		value, err := encodeDynamicValue(arg)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid argument %d: %w", ix, err)
+		}
+		request.Arguments = append(request.Arguments, value)
+	}
+
+	response, err := h.client.CallFunction(ctx, request)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	// The caller only gets a value or an error, so warnings are logged to the
+	// logger of the current call instead.
+	var errs []error
+	for _, diag := range response.Diagnostics {
+		if diag.Severity == proto.Diagnostic_ERROR {
+			errs = append(errs, fmt.Errorf("%s: %s", diag.Summary, diag.Detail))
+			continue
+		}
+		Logger(ctx).Warn(diag.Summary, "detail", diag.Detail, "callee", name)
+	}
+	if len(errs) > 0 {
+		return cty.NilVal, errors.Join(errs...)
+	}
+
+	if response.Result == nil {
+		return cty.NilVal, fmt.Errorf("the host returned no result for function %s", name)
+	}
+	result, _, err := decodeHostValue(true, response.Result.Type, response.Result.Value)
+	return result, err
+}
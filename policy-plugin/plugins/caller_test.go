@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"google.golang.org/grpc"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+type functionHost struct {
+	noHost
+}
+
+func (functionHost) CallFunction(_ context.Context, name string, args ...cty.Value) (cty.Value, error) {
+	if name != "upper" {
+		return cty.NilVal, ErrNoHost
+	}
+	return stdlib.Upper(args[0])
+}
+
+func (functionHost) Log(context.Context, hclog.Level, string) error {
+	return nil
+}
+
+func TestFunctionCaller(t *testing.T) {
+	RegisterFunction("caller_repeat", func(s string, count int) (string, error) {
+		return strings.Repeat(s, count), nil
+	})
+	RegisterFunction("caller_shout", func(ctx context.Context, name string, s string) (string, error) {
+		result, err := FunctionCallerFromContext(ctx).CallFunction(ctx, name, cty.StringVal(s), cty.StringVal("2"))
+		if err != nil {
+			return "", err
+		}
+		result, err = FunctionCallerFromContext(ctx).CallFunction(ctx, "upper", result)
+		if err != nil {
+			return "", err
+		}
+		return result.AsString(), nil
+	})
+
+	ctx := ContextWithHost(context.Background(), functionHost{})
+
+	// The local function converts the string argument to a number, and the
+	// host provides upper.
+	result, err := CallFunctionContext(ctx, "caller_shout", cty.StringVal("caller_repeat"), cty.StringVal("ab"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.RawEquals(cty.StringVal("ABAB")) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+
+	if _, err := CallFunctionContext(ctx, "caller_shout", cty.StringVal("missing"), cty.StringVal("ab")); err == nil {
+		t.Errorf("expected an error calling a missing function")
+	}
+
+	// Without a host providing functions, only local functions can be called.
+	_, err = CallFunctionContext(context.Background(), "caller_shout", cty.StringVal("caller_repeat"), cty.StringVal("ab"))
+	if err == nil || !strings.Contains(err.Error(), "function upper not found") {
+		t.Errorf("expected upper to be missing without a host, got %v", err)
+	}
+}
+
+func TestFunctionCaller_Call(t *testing.T) {
+	RegisterFunction("caller_recurse", func(ctx context.Context, n int) (int, error) {
+		_, err := FunctionCallerFromContext(ctx).CallFunction(ctx, "caller_recurse", cty.NumberIntVal(int64(n+1)))
+		return n, err
+	})
+	RegisterFunction("caller_name", func(ctx context.Context) (string, error) {
+		call, _ := CallFromContext(ctx)
+		return call.Function + " " + call.ID, nil
+	})
+	RegisterFunction("caller_outer", func(ctx context.Context) (string, error) {
+		result, err := FunctionCallerFromContext(ctx).CallFunction(ctx, "caller_name")
+		if err != nil {
+			return "", err
+		}
+		return result.AsString(), nil
+	})
+
+	ctx := ContextWithCall(context.Background(), Call{Function: "caller_outer", ID: "outer"})
+	if _, err := CallFunctionContext(ctx, "caller_recurse", cty.Zero); err == nil || !strings.Contains(err.Error(), "maximum call depth") {
+		t.Errorf("expected recursion to stop at the maximum call depth, got %v", err)
+	}
+
+	result, err := CallFunctionContext(ctx, "caller_outer")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	name, id, _ := strings.Cut(result.AsString(), " ")
+	if name != "caller_name" || len(id) == 0 || id == "outer" {
+		t.Errorf("expected the callee to get its own call, got %q", result.AsString())
+	}
+}
+
+// warningHostClient serves CallFunction with a warning alongside the result.
+type warningHostClient struct {
+	proto.HostClient
+}
+
+func (warningHostClient) CallFunction(context.Context, *proto.CallFunctionRequest, ...grpc.CallOption) (*proto.CallFunctionResponse, error) {
+	result, err := encodeDynamicValue(cty.StringVal("result"))
+	if err != nil {
+		return nil, err
+	}
+	return &proto.CallFunctionResponse{
+		Result: result,
+		Diagnostics: []*proto.Diagnostic{
+			{
+				Severity: proto.Diagnostic_WARNING,
+				Summary:  "Deprecated function",
+				Detail:   "The function \"old\" is deprecated.",
+			},
+		},
+	}, nil
+}
+
+func TestGrpcFunctionHost_Warnings(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := ContextWithLogger(context.Background(), hclog.New(&hclog.LoggerOptions{Output: &logs}))
+
+	host := &grpcFunctionHost{&grpcHost{client: warningHostClient{}}}
+	result, err := host.CallFunction(ctx, "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.RawEquals(cty.StringVal("result")) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !strings.Contains(logs.String(), "Deprecated function") || !strings.Contains(logs.String(), "callee=old") {
+		t.Errorf("expected the warning to be logged, got %q", logs.String())
+	}
+}
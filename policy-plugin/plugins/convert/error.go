@@ -5,65 +5,40 @@ package convert
 
 import (
 	"fmt"
-	"strings"
-)
-
-type Path []*Step
-
-type Step struct {
-	Key     string
-	Indices []string
-}
-
-func (p Path) String() string {
-	var path []string
-	for _, step := range p {
-		if len(step.Indices) == 0 {
-			path = append(path, step.Key)
-		} else {
-			path = append(path, fmt.Sprintf("%s[%s]", step.Key, strings.Join(step.Indices, "][")))
-		}
-	}
-	return strings.Join(path, ".")
-}
-
-func (p Path) Append(key string) Path {
-	return append(p, &Step{Key: key})
-}
-
-func (p Path) WithIndex(index string) Path {
-	if len(p) == 0 {
-		return Path{
-			&Step{
-				Indices: []string{index},
-			},
-		}
-	}
 
-	last := p[len(p)-1]
-	last.Indices = append(last.Indices, index)
-	return p
-}
+	"github.com/zclconf/go-cty/cty"
+)
 
 var (
 	_ error = (*PathError)(nil)
 )
 
+// PathError is an error converting the value at Path.
 type PathError struct {
 	Err  error
 	Path Path
+
+	// CtyPath is Path as a cty path, or nil if Path has wildcards, which it
+	// does for errors converting the element types of collections.
+	CtyPath cty.Path
 }
 
 func (p *PathError) Error() string {
 	return fmt.Sprintf("error at %s: %v", p.Path, p.Err)
 }
 
+func (p *PathError) Unwrap() error {
+	return p.Err
+}
+
 func withPath(path Path, err error) error {
 	if err == nil {
 		return nil
 	}
+	ctyPath, _ := path.ToCtyPath()
 	return &PathError{
-		Err:  err,
-		Path: path,
+		Err:     err,
+		Path:    path,
+		CtyPath: ctyPath,
 	}
 }
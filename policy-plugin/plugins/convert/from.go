@@ -15,6 +15,10 @@ func FromCtyValue(val cty.Value, target reflect.Type) (reflect.Value, error) {
 }
 
 func fromCtyValue(in cty.Value, want reflect.Type, path Path) (reflect.Value, error) {
+	if want == ctyValueType {
+		return reflect.ValueOf(in), nil
+	}
+
 	if in.IsNull() {
 		return reflect.Zero(want), nil
 	}
@@ -0,0 +1,448 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Path identifies a value within another value, as a sequence of attribute
+// names each followed by any number of indices. Paths are immutable, so
+// Append and WithIndex return new paths and never modify the path they are
+// called on.
+type Path []Step
+
+// Step is an attribute of a value, followed by indices into the attribute. The
+// first step of a path has no key if the path starts with an index. Numeric
+// indices are written as numbers, string keys are quoted, and Wildcard matches
+// any element.
+type Step struct {
+	Key     string
+	Indices []string
+}
+
+func (p Path) String() string {
+	var path []string
+	for _, step := range p {
+		if len(step.Indices) == 0 {
+			path = append(path, step.Key)
+		} else {
+			path = append(path, fmt.Sprintf("%s[%s]", step.Key, strings.Join(step.Indices, "][")))
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// Append returns a copy of the path with an additional attribute.
+func (p Path) Append(key string) Path {
+	out := make(Path, len(p), len(p)+1)
+	copy(out, p)
+	return append(out, Step{Key: key})
+}
+
+// WithIndex returns a copy of the path with an additional index into its last
+// step.
+func (p Path) WithIndex(index string) Path {
+	if len(p) == 0 {
+		return Path{
+			{
+				Indices: []string{index},
+			},
+		}
+	}
+
+	out := make(Path, len(p))
+	copy(out, p)
+
+	last := &out[len(out)-1]
+	indices := make([]string, len(last.Indices), len(last.Indices)+1)
+	copy(indices, last.Indices)
+	last.Indices = append(indices, index)
+	return out
+}
+
+// Wildcard matches any attribute when used as the key of a step, and any
+// element when used as an index.
+const Wildcard = "*"
+
+// ParsePath parses a path written in Terraform syntax, such as
+// `a.b[0]["k"]`. Wildcards match any attribute or element, as in `tags[*]` or
+// `rules.*.port`.
+func ParsePath(path string) (Path, error) {
+	var parsed Path
+	for pos := 0; pos < len(path); {
+		if len(parsed) > 0 {
+			if path[pos] != '.' {
+				return nil, fmt.Errorf("invalid path %q: expected '.' at offset %d", path, pos)
+			}
+			pos++
+		}
+
+		end := pos
+		for end < len(path) && path[end] != '.' && path[end] != '[' && path[end] != ']' {
+			end++
+		}
+		step := Step{Key: path[pos:end]}
+		if len(step.Key) == 0 && (len(parsed) > 0 || end >= len(path) || path[end] != '[') {
+			return nil, fmt.Errorf("invalid path %q: expected an attribute name at offset %d", path, pos)
+		}
+		pos = end
+
+		for pos < len(path) && path[pos] == '[' {
+			index, next, err := parseIndex(path, pos)
+			if err != nil {
+				return nil, err
+			}
+			step.Indices = append(step.Indices, index)
+			pos = next
+		}
+		if pos < len(path) && path[pos] == ']' {
+			return nil, fmt.Errorf("invalid path %q: unexpected ']' at offset %d", path, pos)
+		}
+
+		parsed = append(parsed, step)
+	}
+	return parsed, nil
+}
+
+// parseIndex parses the index in brackets at pos, and returns it in the form
+// used by Step along with the offset after the closing bracket.
+func parseIndex(path string, pos int) (string, int, error) {
+	start := pos + 1
+	if start < len(path) && path[start] == '"' {
+		end := start + 1
+		for end < len(path) && path[end] != '"' {
+			if path[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end+1 >= len(path) || path[end+1] != ']' {
+			return "", 0, fmt.Errorf("invalid path %q: unterminated index at offset %d", path, pos)
+		}
+		key, err := strconv.Unquote(path[start : end+1])
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid path %q: invalid key at offset %d: %w", path, start, err)
+		}
+		return strconv.Quote(key), end + 2, nil
+	}
+
+	end := strings.IndexByte(path[start:], ']')
+	if end < 0 {
+		return "", 0, fmt.Errorf("invalid path %q: unterminated index at offset %d", path, pos)
+	}
+	index := path[start : start+end]
+	if index != Wildcard {
+		if _, err := strconv.Atoi(index); err != nil {
+			return "", 0, fmt.Errorf("invalid path %q: index %q must be a number, a quoted key or %s", path, index, Wildcard)
+		}
+	}
+	return index, start + end + 1, nil
+}
+
+// FromCtyPath converts a cty path into a Path. Paths that index sets, or use
+// unknown keys, can't be converted.
+func FromCtyPath(path cty.Path) (Path, error) {
+	var converted Path
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			converted = converted.Append(step.Name)
+		case cty.IndexStep:
+			if !step.Key.IsKnown() || step.Key.IsNull() {
+				return nil, errors.New("cannot convert paths with unknown or null keys")
+			}
+
+			var index string
+			switch step.Key.Type() {
+			case cty.Number:
+				index = step.Key.AsBigFloat().Text('f', -1)
+			case cty.String:
+				index = strconv.Quote(step.Key.AsString())
+			default:
+				return nil, fmt.Errorf("cannot convert paths with %s keys", step.Key.Type().FriendlyName())
+			}
+
+			converted = converted.WithIndex(index)
+		}
+	}
+	return converted, nil
+}
+
+// ToCtyPath converts the path into a cty path. Paths with wildcards can't be
+// converted.
+func (p Path) ToCtyPath() (cty.Path, error) {
+	var path cty.Path
+	for _, step := range p {
+		if step.Key == Wildcard {
+			return nil, errors.New("cannot convert paths with wildcards")
+		}
+		if len(step.Key) > 0 {
+			path = path.GetAttr(step.Key)
+		}
+
+		for _, index := range step.Indices {
+			key, err := indexKey(index)
+			if err != nil {
+				return nil, err
+			}
+			path = path.Index(key)
+		}
+	}
+	return path, nil
+}
+
+// indexKey converts an index of a step into a cty value.
+func indexKey(index string) (cty.Value, error) {
+	if index == Wildcard {
+		return cty.NilVal, errors.New("cannot convert paths with wildcards")
+	}
+	if strings.HasPrefix(index, "\"") {
+		key, err := strconv.Unquote(index)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid index %s: %w", index, err)
+		}
+		return cty.StringVal(key), nil
+	}
+	key, err := cty.ParseNumberVal(index)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("invalid index %s: %w", index, err)
+	}
+	return key, nil
+}
+
+// Apply returns the value at the path within value. Attribute names can also
+// look up keys of maps. Paths with wildcards can't be applied, use Expand to
+// find the paths they match first.
+func (p Path) Apply(value cty.Value) (cty.Value, error) {
+	path, err := p.ToCtyPath()
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	for ix, step := range path {
+		value, err = applyStep(value, step)
+		if err != nil {
+			return cty.NilVal, path[:ix+1].NewError(err)
+		}
+	}
+	return value, nil
+}
+
+// applyStep applies a single step of a path to value, using attribute names as
+// the keys of maps and string keys as the names of object attributes.
+func applyStep(value cty.Value, step cty.PathStep) (cty.Value, error) {
+	ty := value.Type()
+	switch s := step.(type) {
+	case cty.GetAttrStep:
+		if ty.IsMapType() {
+			step = cty.IndexStep{Key: cty.StringVal(s.Name)}
+		}
+	case cty.IndexStep:
+		if ty.IsObjectType() && s.Key.Type() == cty.String {
+			step = cty.GetAttrStep{Name: s.Key.AsString()}
+		}
+	}
+	return step.Apply(value)
+}
+
+// ApplyGo returns the value at the path within a Go value, which can be a
+// struct with cty tags, a map with string keys, a slice, or any pointer to
+// them, as accepted by FromCtyValue.
+func (p Path) ApplyGo(value interface{}) (interface{}, error) {
+	current := reflect.ValueOf(value)
+	var done Path
+
+	visit := func(lookup func(reflect.Value) (reflect.Value, error)) error {
+		for current.Kind() == reflect.Pointer || current.Kind() == reflect.Interface {
+			if current.IsNil() {
+				return withPath(done, errors.New("nil value"))
+			}
+			current = current.Elem()
+		}
+
+		next, err := lookup(current)
+		if err != nil {
+			return withPath(done, err)
+		}
+		current = next
+		return nil
+	}
+
+	for _, step := range p {
+		if step.Key == Wildcard {
+			return nil, errors.New("cannot apply paths with wildcards")
+		}
+		if len(step.Key) > 0 {
+			done = done.Append(step.Key)
+			if err := visit(func(v reflect.Value) (reflect.Value, error) {
+				return goAttribute(v, step.Key)
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, index := range step.Indices {
+			key, err := indexKey(index)
+			if err != nil {
+				return nil, err
+			}
+			done = done.WithIndex(index)
+			if err := visit(func(v reflect.Value) (reflect.Value, error) {
+				return goIndex(v, key)
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return current.Interface(), nil
+}
+
+// goAttribute returns the field of a struct with the cty tag name, or the
+// element of a map with the key name.
+func goAttribute(value reflect.Value, name string) (reflect.Value, error) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for ix := 0; ix < value.NumField(); ix++ {
+			if value.Type().Field(ix).Tag.Get("cty") == name {
+				return value.Field(ix), nil
+			}
+		}
+		return reflect.Value{}, fmt.Errorf("no attribute %q", name)
+	case reflect.Map:
+		return goIndex(value, cty.StringVal(name))
+	}
+	return reflect.Value{}, fmt.Errorf("cannot get attribute %q of %s", name, value.Kind())
+}
+
+// goIndex returns the element of a slice or map with the key.
+func goIndex(value reflect.Value, key cty.Value) (reflect.Value, error) {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		if key.Type() != cty.Number {
+			return reflect.Value{}, errors.New("lists must be indexed with numbers")
+		}
+		index, accuracy := key.AsBigFloat().Int64()
+		if accuracy != 0 || index < 0 || index >= int64(value.Len()) {
+			return reflect.Value{}, fmt.Errorf("index %s out of range", key.AsBigFloat().Text('f', -1))
+		}
+		return value.Index(int(index)), nil
+	case reflect.Map:
+		if key.Type() != cty.String || value.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, errors.New("maps must be indexed with string keys")
+		}
+		element := value.MapIndex(reflect.ValueOf(key.AsString()).Convert(value.Type().Key()))
+		if !element.IsValid() {
+			return reflect.Value{}, fmt.Errorf("no key %q", key.AsString())
+		}
+		return element, nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot index %s", value.Kind())
+}
+
+// Match returns true if path matches p, where each wildcard in p matches any
+// single attribute or element.
+func (p Path) Match(path Path) bool {
+	steps, other := p.flatten(), path.flatten()
+	if len(steps) != len(other) {
+		return false
+	}
+	for ix, step := range steps {
+		if step.name == Wildcard {
+			continue
+		}
+		if step.attribute != other[ix].attribute || step.name != other[ix].name {
+			return false
+		}
+	}
+	return true
+}
+
+// Expand returns every path without wildcards that p matches within value,
+// ordered as the elements of value. Paths without wildcards expand to
+// themselves if they exist in value.
+func (p Path) Expand(value cty.Value) ([]Path, error) {
+	var paths []Path
+	var expand func(value cty.Value, steps []flatStep, done cty.Path) error
+	expand = func(value cty.Value, steps []flatStep, done cty.Path) error {
+		if len(steps) == 0 {
+			path, err := FromCtyPath(done)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			return nil
+		}
+		if value.IsNull() || !value.IsKnown() {
+			return nil
+		}
+
+		step := steps[0]
+		if step.name != Wildcard {
+			var next cty.PathStep = cty.GetAttrStep{Name: step.name}
+			if !step.attribute {
+				key, err := indexKey(step.name)
+				if err != nil {
+					return err
+				}
+				next = cty.IndexStep{Key: key}
+			}
+
+			element, err := applyStep(value, next)
+			if err != nil {
+				// Paths that don't exist in the value match nothing.
+				return nil
+			}
+			return expand(element, steps[1:], append(done.Copy(), next))
+		}
+
+		ty := value.Type()
+		if !ty.IsObjectType() && !ty.IsMapType() && !ty.IsListType() && !ty.IsTupleType() {
+			return nil
+		}
+		for it := value.ElementIterator(); it.Next(); {
+			key, element := it.Element()
+
+			var next cty.PathStep = cty.IndexStep{Key: key}
+			if ty.IsObjectType() {
+				next = cty.GetAttrStep{Name: key.AsString()}
+			}
+			if err := expand(element, steps[1:], append(done.Copy(), next)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := expand(value, p.flatten(), nil); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// flatStep is a single attribute or index of a path.
+type flatStep struct {
+	attribute bool
+	name      string
+}
+
+// flatten returns the attributes and indices of the path in order.
+func (p Path) flatten() []flatStep {
+	var steps []flatStep
+	for _, step := range p {
+		if len(step.Key) > 0 {
+			steps = append(steps, flatStep{attribute: true, name: step.Key})
+		}
+		for _, index := range step.Indices {
+			steps = append(steps, flatStep{name: index})
+		}
+	}
+	return steps
+}
@@ -0,0 +1,509 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty-debug/ctydebug"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// randomType generates a random type of nested lists, maps and objects with
+// string leaves.
+func randomType(rng *rand.Rand, depth int) cty.Type {
+	if depth == 0 {
+		return cty.String
+	}
+
+	switch rng.Intn(4) {
+	case 0:
+		return cty.List(randomType(rng, depth-1))
+	case 1:
+		return cty.Map(randomType(rng, depth-1))
+	case 2:
+		attributes := make(map[string]cty.Type)
+		for ix := 0; ix < 1+rng.Intn(3); ix++ {
+			attributes[fmt.Sprintf("a%d", ix)] = randomType(rng, depth-1)
+		}
+		return cty.Object(attributes)
+	default:
+		return cty.String
+	}
+}
+
+// randomValue generates a random value of type ty, and records the path of
+// every leaf built the same way the conversions build their error paths. The
+// leaves are returned by leaf for their paths.
+func randomValue(rng *rand.Rand, ty cty.Type, path Path, leaves map[string]Path, leaf func(Path) cty.Value) cty.Value {
+	switch {
+	case ty.IsListType():
+		var elements []cty.Value
+		for ix := 0; ix < 1+rng.Intn(3); ix++ {
+			elements = append(elements, randomValue(rng, ty.ElementType(), path.WithIndex(fmt.Sprintf("%d", ix)), leaves, leaf))
+		}
+		return cty.ListVal(elements)
+	case ty.IsMapType():
+		elements := make(map[string]cty.Value)
+		for ix := 0; ix < 1+rng.Intn(3); ix++ {
+			key := fmt.Sprintf("k%d", ix)
+			elements[key] = randomValue(rng, ty.ElementType(), path.WithIndex(fmt.Sprintf("%q", key)), leaves, leaf)
+		}
+		return cty.MapVal(elements)
+	case ty.IsObjectType():
+		// Attributes are generated in order, so the same seed always generates
+		// the same value.
+		attributes := make(map[string]cty.Value)
+		for ix := 0; ix < len(ty.AttributeTypes()); ix++ {
+			name := fmt.Sprintf("a%d", ix)
+			attributes[name] = randomValue(rng, ty.AttributeType(name), path.Append(name), leaves, leaf)
+		}
+		return cty.ObjectVal(attributes)
+	default:
+		leaves[path.String()] = path
+		return leaf(path)
+	}
+}
+
+// withLeaves returns ty with its string leaves replaced by leaf.
+func withLeaves(ty cty.Type, leaf cty.Type) cty.Type {
+	switch {
+	case ty.IsListType():
+		return cty.List(withLeaves(ty.ElementType(), leaf))
+	case ty.IsMapType():
+		return cty.Map(withLeaves(ty.ElementType(), leaf))
+	case ty.IsObjectType():
+		attributes := make(map[string]cty.Type)
+		for name, attribute := range ty.AttributeTypes() {
+			attributes[name] = withLeaves(attribute, leaf)
+		}
+		return cty.Object(attributes)
+	default:
+		return leaf
+	}
+}
+
+// goType returns the Go type for ty, with leaf as the type of its leaves.
+func goType(ty cty.Type, leaf reflect.Type) reflect.Type {
+	switch {
+	case ty.IsListType():
+		return reflect.SliceOf(goType(ty.ElementType(), leaf))
+	case ty.IsMapType():
+		return reflect.MapOf(reflect.TypeOf(""), goType(ty.ElementType(), leaf))
+	case ty.IsObjectType():
+		var fields []reflect.StructField
+		for ix := 0; ix < len(ty.AttributeTypes()); ix++ {
+			name := fmt.Sprintf("a%d", ix)
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("A%d", ix),
+				Type: goType(ty.AttributeType(name), leaf),
+				Tag:  reflect.StructTag(fmt.Sprintf("cty:%q", name)),
+			})
+		}
+		return reflect.StructOf(fields)
+	default:
+		return leaf
+	}
+}
+
+func TestPath_Properties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for iteration := 0; iteration < 500; iteration++ {
+		ty := randomType(rng, 1+rng.Intn(4))
+		for ty == cty.String {
+			ty = randomType(rng, 1+rng.Intn(4))
+		}
+
+		seed := rng.Int63()
+		leaves := make(map[string]Path)
+		value := randomValue(rand.New(rand.NewSource(seed)), ty, nil, leaves, func(path Path) cty.Value {
+			return cty.StringVal(path.String())
+		})
+
+		for name, path := range leaves {
+			// Sibling paths built from the same parent don't share steps.
+			if path.String() != name {
+				t.Fatalf("path %s changed to %s after building its siblings", name, path)
+			}
+
+			parsed, err := ParsePath(name)
+			if err != nil {
+				t.Fatalf("unexpected error parsing %s: %s", name, err)
+			}
+			if !reflect.DeepEqual(parsed, path) {
+				t.Fatalf("expected %s to parse to %#v, got %#v", name, path, parsed)
+			}
+
+			ctyPath, err := path.ToCtyPath()
+			if err != nil {
+				t.Fatalf("unexpected error converting %s: %s", name, err)
+			}
+			back, err := FromCtyPath(ctyPath)
+			if err != nil {
+				t.Fatalf("unexpected error converting %s back: %s", name, err)
+			}
+			if back.String() != name {
+				t.Fatalf("expected %s after a round trip, got %s", name, back)
+			}
+
+			leaf, err := path.Apply(value)
+			if err != nil {
+				t.Fatalf("unexpected error applying %s: %s", name, err)
+			}
+			if !leaf.RawEquals(cty.StringVal(name)) {
+				t.Fatalf("expected the leaf at %s, got %#v", name, leaf)
+			}
+
+			if !path.WithIndex(Wildcard).Match(path.WithIndex("0")) {
+				t.Fatalf("expected %s to match its own elements", path.WithIndex(Wildcard))
+			}
+		}
+
+		// Each leaf in turn is the only one that fails to convert, so the
+		// conversions must report exactly its path.
+		for name := range leaves {
+			checkErrorPath(t, name, func() error {
+				// Null leaves convert without errors, but channels don't.
+				target := randomValue(rand.New(rand.NewSource(seed)), ty, nil, make(map[string]Path), func(path Path) cty.Value {
+					if path.String() == name {
+						return cty.StringVal(name)
+					}
+					return cty.NullVal(cty.String)
+				})
+				_, err := FromCtyValue(target, goType(ty, reflect.TypeOf(make(chan int))))
+				return err
+			})
+
+			checkErrorPath(t, name, func() error {
+				// Every leaf but the target is a valid number.
+				target := randomValue(rand.New(rand.NewSource(seed)), ty, nil, make(map[string]Path), func(path Path) cty.Value {
+					if path.String() == name {
+						return cty.StringVal(name)
+					}
+					return cty.StringVal("1")
+				})
+				in, err := FromCtyValue(target, goType(ty, ctyValueType))
+				if err != nil {
+					return err
+				}
+				_, err = ToCtyValue(in, withLeaves(ty, cty.Number))
+				return err
+			})
+		}
+	}
+}
+
+// checkErrorPath checks that convert fails with a PathError at expected.
+func checkErrorPath(t *testing.T, expected string, convert func() error) {
+	t.Helper()
+
+	err := convert()
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected a path error at %s, got %v", expected, err)
+	}
+	if pathErr.Path.String() != expected {
+		t.Fatalf("expected the error at %s, got %s", expected, pathErr.Path)
+	}
+	parsed, err := ParsePath(expected)
+	if err != nil {
+		t.Fatalf("unexpected error parsing %s: %s", expected, err)
+	}
+	if ctyPath, _ := parsed.ToCtyPath(); !pathErr.CtyPath.Equals(ctyPath) {
+		t.Fatalf("expected the cty path of %s, got %#v", expected, pathErr.CtyPath)
+	}
+}
+
+func TestPath_Immutable(t *testing.T) {
+	parent := Path{}.Append("a").WithIndex("0")
+	first := parent.WithIndex("1")
+	second := parent.WithIndex("2")
+	child := parent.Append("b")
+
+	for expected, path := range map[string]Path{
+		"a[0]":    parent,
+		"a[0][1]": first,
+		"a[0][2]": second,
+		"a[0].b":  child,
+	} {
+		if path.String() != expected {
+			t.Errorf("expected %s, got %s", expected, path)
+		}
+	}
+}
+
+func TestFromCtyValue_ErrorPath(t *testing.T) {
+	type resource struct {
+		Items [][]chan int `cty:"items"`
+	}
+
+	// Null elements convert without errors, so only the last element fails.
+	value := cty.ObjectVal(map[string]cty.Value{
+		"items": cty.ListVal([]cty.Value{
+			cty.NullVal(cty.List(cty.Number)),
+			cty.ListVal([]cty.Value{
+				cty.NullVal(cty.Number),
+				cty.NullVal(cty.Number),
+				cty.NumberIntVal(1),
+			}),
+		}),
+	})
+
+	_, err := FromCtyValue(value, reflect.TypeOf(resource{}))
+
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected a path error, got %v", err)
+	}
+	if pathErr.Path.String() != "items[1][2]" {
+		t.Errorf("expected the error at items[1][2], got %s", pathErr.Path)
+	}
+	if !pathErr.CtyPath.Equals(cty.GetAttrPath("items").Index(cty.NumberIntVal(1)).Index(cty.NumberIntVal(2))) {
+		t.Errorf("unexpected cty path: %#v", pathErr.CtyPath)
+	}
+}
+
+func TestToCtyType_ErrorPath(t *testing.T) {
+	type resource struct {
+		Tags map[string][]interface{} `cty:"tags"`
+	}
+
+	_, err := ToCtyType(reflect.TypeOf(resource{}))
+
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected a path error, got %v", err)
+	}
+	if pathErr.Path.String() != "tags[*][*]" || pathErr.CtyPath != nil {
+		t.Errorf("unexpected error path %s (%#v)", pathErr.Path, pathErr.CtyPath)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tcs := map[string]struct {
+		path     string
+		expected string
+		cty      cty.Path
+	}{
+		"attributes": {
+			path:     "a.b",
+			expected: "a.b",
+			cty:      cty.GetAttrPath("a").GetAttr("b"),
+		},
+		"indices": {
+			path:     `a.b[0]["k"]`,
+			expected: `a.b[0]["k"]`,
+			cty:      cty.GetAttrPath("a").GetAttr("b").Index(cty.NumberIntVal(0)).Index(cty.StringVal("k")),
+		},
+		"root index": {
+			path:     `[1].name`,
+			expected: `[1].name`,
+			cty:      cty.IndexPath(cty.NumberIntVal(1)).GetAttr("name"),
+		},
+		"escaped keys": {
+			path:     `tags["a.b[\"c\"]"]`,
+			expected: `tags["a.b[\"c\"]"]`,
+			cty:      cty.GetAttrPath("tags").Index(cty.StringVal(`a.b["c"]`)),
+		},
+		"wildcards": {
+			path:     `rules[*].ports.*`,
+			expected: `rules[*].ports.*`,
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			path, err := ParsePath(tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if path.String() != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, path)
+			}
+
+			if tc.cty == nil {
+				if _, err := path.ToCtyPath(); err == nil {
+					t.Errorf("expected paths with wildcards not to convert")
+				}
+				return
+			}
+
+			converted, err := path.ToCtyPath()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !converted.Equals(tc.cty) {
+				t.Errorf("unexpected cty path: %#v", converted)
+			}
+
+			back, err := FromCtyPath(converted)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if back.String() != tc.expected {
+				t.Errorf("expected %s after a round trip, got %s", tc.expected, back)
+			}
+		})
+	}
+}
+
+func TestParsePath_Invalid(t *testing.T) {
+	for _, path := range []string{".a", "a.", "a..b", "a[", "a[x]", `a["k`, "a]", "a[0]b", "a.[0]"} {
+		if parsed, err := ParsePath(path); err == nil {
+			t.Errorf("expected %q to be invalid, got %s", path, parsed)
+		}
+	}
+}
+
+func TestPath_Apply(t *testing.T) {
+	value := cty.ObjectVal(map[string]cty.Value{
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Owner": cty.StringVal("platform"),
+		}),
+		"rules": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(22)}),
+			cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(443)}),
+		}),
+	})
+
+	for path, expected := range map[string]cty.Value{
+		"tags.Owner":        cty.StringVal("platform"),
+		`tags["Owner"]`:     cty.StringVal("platform"),
+		`rules[1].port`:     cty.NumberIntVal(443),
+		`rules[0]["port"]`:  cty.NumberIntVal(22),
+		`["rules"][0].port`: cty.NumberIntVal(22),
+	} {
+		parsed, err := ParsePath(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		actual, err := parsed.Apply(value)
+		if err != nil {
+			t.Errorf("unexpected error applying %s: %s", path, err)
+			continue
+		}
+		if diff := cmp.Diff(expected, actual, ctydebug.CmpOptions); diff != "" {
+			t.Errorf("unexpected value at %s (-want +got):\n%s", path, diff)
+		}
+	}
+
+	parsed, _ := ParsePath("rules[2].port")
+	if _, err := parsed.Apply(value); err == nil {
+		t.Errorf("expected an error for a missing element")
+	}
+}
+
+func TestPath_ApplyGo(t *testing.T) {
+	type rule struct {
+		Port int `cty:"port"`
+	}
+	type resource struct {
+		Tags  map[string]string `cty:"tags"`
+		Rules []*rule           `cty:"rules"`
+	}
+	value := &resource{
+		Tags:  map[string]string{"Owner": "platform"},
+		Rules: []*rule{{Port: 22}, {Port: 443}},
+	}
+
+	for path, expected := range map[string]interface{}{
+		"tags.Owner":    "platform",
+		`tags["Owner"]`: "platform",
+		"rules[1].port": 443,
+	} {
+		parsed, err := ParsePath(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		actual, err := parsed.ApplyGo(value)
+		if err != nil {
+			t.Errorf("unexpected error applying %s: %s", path, err)
+			continue
+		}
+		if actual != expected {
+			t.Errorf("expected %v at %s, got %v", expected, path, actual)
+		}
+	}
+
+	parsed, _ := ParsePath("rules[2].port")
+	if _, err := parsed.ApplyGo(value); err == nil || err.Error() != "error at rules[2]: index 2 out of range" {
+		t.Errorf("expected an error for a missing element, got %v", err)
+	}
+}
+
+func TestPath_Match(t *testing.T) {
+	tcs := []struct {
+		glob, path string
+		expected   bool
+	}{
+		{`tags[*]`, `tags["Owner"]`, true},
+		{`tags.*`, `tags["Owner"]`, true},
+		{`rules[*].port`, `rules[3].port`, true},
+		{`rules[*].port`, `rules[3].protocol`, false},
+		{`rules[*]`, `rules[3].port`, false},
+		{`tags["Owner"]`, `tags["Owner"]`, true},
+		{`tags.Owner`, `tags["Owner"]`, false},
+	}
+	for _, tc := range tcs {
+		glob, err := ParsePath(tc.glob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := ParsePath(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := glob.Match(path); actual != tc.expected {
+			t.Errorf("expected %s matching %s to be %t", tc.glob, tc.path, tc.expected)
+		}
+	}
+}
+
+func TestPath_Expand(t *testing.T) {
+	value := cty.ObjectVal(map[string]cty.Value{
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Owner": cty.StringVal("platform"),
+			"Team":  cty.StringVal("policy"),
+		}),
+		"rules": cty.TupleVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(22)}),
+			cty.ObjectVal(map[string]cty.Value{"protocol": cty.StringVal("icmp")}),
+			cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(443)}),
+		}),
+	})
+
+	for glob, expected := range map[string][]string{
+		"tags[*]":       {`tags["Owner"]`, `tags["Team"]`},
+		"rules.*.port":  {"rules[0].port", "rules[2].port"},
+		"*":             {"rules", "tags"},
+		"tags.Owner":    {"tags.Owner"},
+		"tags.Missing":  nil,
+		"rules[*].*":    {"rules[0].port", "rules[1].protocol", "rules[2].port"},
+		"rules[1].port": nil,
+	} {
+		parsed, err := ParsePath(glob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths, err := parsed.Expand(value)
+		if err != nil {
+			t.Fatalf("unexpected error expanding %s: %s", glob, err)
+		}
+
+		var actual []string
+		for _, path := range paths {
+			actual = append(actual, path.String())
+			if !parsed.Match(path) {
+				t.Errorf("expected %s to match its expansion %s", glob, path)
+			}
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Errorf("unexpected expansion of %s (-want +got):\n%s", glob, diff)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"reflect"
 
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 )
 
 func ToCtyValue(val reflect.Value, want cty.Type) (cty.Value, error) {
@@ -15,6 +16,21 @@ func ToCtyValue(val reflect.Value, want cty.Type) (cty.Value, error) {
 }
 
 func toCtyValue(in reflect.Value, want cty.Type, path Path) (cty.Value, error) {
+	if in.Type() == ctyValueType {
+		value := in.Interface().(cty.Value)
+		if value.Type() == cty.NilType {
+			return cty.NullVal(want), nil
+		}
+		if want == cty.DynamicPseudoType {
+			return value, nil
+		}
+		converted, err := convert.Convert(value, want)
+		if err != nil {
+			return cty.NullVal(want), withPath(path, err)
+		}
+		return converted, nil
+	}
+
 	if in.Type().Kind() == reflect.Pointer {
 		if in.IsNil() || in.IsZero() {
 			return cty.NullVal(want), nil
@@ -10,11 +10,19 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
+// ctyValueType is the Go type of cty values, which functions can accept and
+// return to handle values of any type.
+var ctyValueType = reflect.TypeOf(cty.Value{})
+
 func ToCtyType(from reflect.Type) (cty.Type, error) {
 	return toCtyType(from, nil)
 }
 
 func toCtyType(from reflect.Type, path Path) (cty.Type, error) {
+	if from == ctyValueType {
+		return cty.DynamicPseudoType, nil
+	}
+
 	if from.Kind() == reflect.Interface {
 		// We can't support interface types because we need to know the concrete
 		// type when converting back and forth between Go and cty. Users can
@@ -42,13 +50,13 @@ func toCtyType(from reflect.Type, path Path) (cty.Type, error) {
 		if key := from.Key(); key.Kind() != reflect.String {
 			return cty.NilType, withPath(path, fmt.Errorf("map keys must be strings, but was %s", key.Kind()))
 		}
-		element, err := toCtyType(from.Elem(), path.WithIndex("\"*\""))
+		element, err := toCtyType(from.Elem(), path.WithIndex(Wildcard))
 		if err != nil {
 			return cty.NilType, err
 		}
 		return cty.Map(element), nil
 	case reflect.Slice:
-		element, err := toCtyType(from.Elem(), path.WithIndex("*"))
+		element, err := toCtyType(from.Elem(), path.WithIndex(Wildcard))
 		if err != nil {
 			return cty.NilType, err
 		}
@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+const (
+	// DebugEnvVar can be set to true to make Serve start the plugin in debug
+	// mode, see ServeDebug.
+	DebugEnvVar = "TF_POLICY_PLUGIN_DEBUG"
+
+	// ReattachEnvVar is the environment variable hosts read the ReattachConfig
+	// for a plugin running in debug mode from.
+	ReattachEnvVar = "TF_POLICY_PLUGIN_REATTACH"
+)
+
+// ReattachConfig is the JSON representation of the information a host needs to
+// connect to a plugin that is running in debug mode.
+type ReattachConfig struct {
+	Protocol        string             `json:"protocol"`
+	ProtocolVersion int                `json:"protocol_version"`
+	Pid             int                `json:"pid"`
+	Test            bool               `json:"test"`
+	Addr            ReattachConfigAddr `json:"addr"`
+}
+
+// ReattachConfigAddr is the JSON representation of a net.Addr.
+type ReattachConfigAddr struct {
+	Network string `json:"network"`
+	String  string `json:"string"`
+}
+
+// ServeDebug serves the plugin in the foreground, instead of waiting to be
+// launched by a host. This means the plugin can be started under a debugger,
+// such as Delve, and the host told to connect to it using the reattach
+// configuration written to w.
+//
+// ServeDebug blocks until ctx is cancelled or the plugin is shut down.
+func ServeDebug(ctx context.Context, w io.Writer, opts ...ServeOption) error {
+	config := newServeConfig(opts)
+
+	stopTracing, err := config.startTracing(ctx)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	return serveDebug(ctx, config, w)
+}
+
+func serveDebug(ctx context.Context, config *serveConfig, w io.Writer) error {
+	reattachCh := make(chan *plugin.ReattachConfig)
+	closeCh := make(chan struct{})
+
+	serveConfig := config.pluginServeConfig()
+	serveConfig.Logger = config.logger
+	// Without a host launching the plugin there's nothing to negotiate with,
+	// so serve the latest version and let the host check the reattach config.
+	serveConfig.VersionedPlugins = map[int]plugin.PluginSet{
+		LatestProtocolVersion: serveConfig.VersionedPlugins[LatestProtocolVersion],
+	}
+	serveConfig.Test = &plugin.ServeTestConfig{
+		Context:          ctx,
+		ReattachConfigCh: reattachCh,
+		CloseCh:          closeCh,
+	}
+	go plugin.Serve(serveConfig)
+
+	var reattach *plugin.ReattachConfig
+	select {
+	case reattach = <-reattachCh:
+	case <-closeCh:
+		return errors.New("plugin exited before it started serving")
+	}
+
+	data, err := json.Marshal(ReattachConfig{
+		Protocol:        string(reattach.Protocol),
+		ProtocolVersion: reattach.ProtocolVersion,
+		Pid:             reattach.Pid,
+		Test:            reattach.Test,
+		Addr: ReattachConfigAddr{
+			Network: reattach.Addr.Network(),
+			String:  reattach.Addr.String(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Plugin started in debug mode. To connect to it, set the following environment\n")
+	fmt.Fprintf(w, "variable in the host process:\n\n")
+	fmt.Fprintf(w, "\t%s='%s'\n\n", ReattachEnvVar, data)
+
+	<-closeCh
+	config.shutdownAfterServe()
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestExecuteFunction_Deprecation(t *testing.T) {
+	RegisterFunction("deprecated", func(s string) (string, error) {
+		return s, nil
+	}, WithDeprecation(Deprecation{
+		Message:        "Superseded by a faster implementation.",
+		Replacement:    "replacement",
+		RemovalVersion: "2.0.0",
+	}))
+
+	server := new(GrpcServer)
+
+	list, err := server.ListFunctions(context.Background(), new(proto.ListFunctionsRequest))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	deprecation := list.Functions["deprecated"].Deprecation
+	if deprecation == nil || deprecation.Replacement != "replacement" || deprecation.RemovalVersion != "2.0.0" {
+		t.Errorf("unexpected deprecation: %v", deprecation)
+	}
+
+	argument, err := msgpack.Marshal(cty.StringVal("hello"), cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every call should warn, not just the first.
+	for i := 0; i < 2; i++ {
+		response, err := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+			Name:      "deprecated",
+			Arguments: [][]byte{argument},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(response.Result) == 0 {
+			t.Errorf("expected a result alongside the warning")
+		}
+
+		if len(response.Diagnostics) != 1 {
+			t.Fatalf("expected exactly one diagnostic, got %d", len(response.Diagnostics))
+		}
+		diagnostic := response.Diagnostics[0]
+		if diagnostic.Severity != proto.Diagnostic_WARNING || diagnostic.Summary != "Deprecated function" {
+			t.Errorf("unexpected diagnostic: %v", diagnostic)
+		}
+		if expected := `The function "deprecated" is deprecated: Superseded by a faster implementation. Use "replacement" instead. It will be removed in version 2.0.0.`; diagnostic.Detail != expected {
+			t.Errorf("unexpected detail: %s", diagnostic.Detail)
+		}
+	}
+
+	docs, err := functionDocs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, doc := range docs {
+		if doc.Name != "deprecated" {
+			continue
+		}
+
+		if diff := cmp.Diff(&Deprecation{
+			Message:        "Superseded by a faster implementation.",
+			Replacement:    "replacement",
+			RemovalVersion: "2.0.0",
+		}, doc.Deprecation); diff != "" {
+			t.Errorf("unexpected docs deprecation (-want +got):\n%s", diff)
+		}
+		if notice := markdownDeprecation(doc.Deprecation); notice != "> **Deprecated.** Superseded by a faster implementation. Use `replacement` instead. It will be removed in version 2.0.0.\n\n" {
+			t.Errorf("unexpected markdown notice: %q", notice)
+		}
+	}
+}
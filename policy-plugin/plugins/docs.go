@@ -0,0 +1,213 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+const (
+	// DocsEnvVar can be set to one of the supported docs formats to make Serve
+	// write the documentation for the registered functions to stdout and exit
+	// instead of serving the plugin.
+	DocsEnvVar = "TF_POLICY_PLUGIN_DOCS"
+
+	DocsFormatMarkdown = "markdown"
+	DocsFormatJSON     = "json"
+)
+
+// FunctionDocs is the documentation for a single function, as rendered by
+// Docs.
+type FunctionDocs struct {
+	Name              string          `json:"name"`
+	Namespace         string          `json:"namespace,omitempty"`
+	Aliases           []string        `json:"aliases,omitempty"`
+	Signature         string          `json:"signature"`
+	Description       string          `json:"description,omitempty"`
+	Parameters        []ParameterDocs `json:"parameters"`
+	VariadicParameter *ParameterDocs  `json:"variadic_parameter,omitempty"`
+	ReturnType        string          `json:"return_type"`
+	Deprecation       *Deprecation    `json:"deprecation,omitempty"`
+}
+
+// ParameterDocs is the documentation for a single function parameter, as
+// rendered by Docs.
+type ParameterDocs struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	AllowNull   bool   `json:"allow_null"`
+}
+
+// Docs writes the documentation for every registered function to w in the
+// given format, either DocsFormatMarkdown or DocsFormatJSON.
+func Docs(w io.Writer, format string) error {
+	docs, err := functionDocs()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case DocsFormatMarkdown:
+		return writeMarkdownDocs(w, docs)
+	case DocsFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Functions []FunctionDocs `json:"functions"`
+		}{
+			Functions: docs,
+		})
+	default:
+		return fmt.Errorf("unsupported docs format %q, expected %q or %q", format, DocsFormatMarkdown, DocsFormatJSON)
+	}
+}
+
+// functionDocs builds the documentation from the same representation the
+// ListFunctions RPC returns, so the docs always match what the runtime sees.
+func functionDocs() ([]FunctionDocs, error) {
+	response, err := new(GrpcServer).ListFunctions(context.Background(), new(proto.ListFunctionsRequest))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []FunctionDocs
+	for name, fn := range response.Functions {
+		doc := FunctionDocs{
+			Name:        name,
+			Namespace:   fn.Namespace,
+			Aliases:     fn.Aliases,
+			Description: fn.Description,
+			Parameters:  make([]ParameterDocs, 0, len(fn.Parameters)),
+		}
+		if fn.Deprecation != nil {
+			doc.Deprecation = &Deprecation{
+				Message:        fn.Deprecation.Message,
+				Replacement:    fn.Deprecation.Replacement,
+				RemovalVersion: fn.Deprecation.RemovalVersion,
+			}
+		}
+
+		var signature []string
+		for ix, parameter := range fn.Parameters {
+			param, err := parameterDocs(ix, parameter)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter %d for %s: %w", ix, name, err)
+			}
+			doc.Parameters = append(doc.Parameters, param)
+			signature = append(signature, fmt.Sprintf("%s %s", param.Name, param.Type))
+		}
+
+		if fn.VariadicParameter != nil {
+			param, err := parameterDocs(len(fn.Parameters), fn.VariadicParameter)
+			if err != nil {
+				return nil, fmt.Errorf("invalid variadic parameter for %s: %w", name, err)
+			}
+			doc.VariadicParameter = &param
+			signature = append(signature, fmt.Sprintf("%s... %s", param.Name, param.Type))
+		}
+
+		returnType, err := ctyjson.UnmarshalType(fn.ReturnType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid return type for %s: %w", name, err)
+		}
+		doc.ReturnType = typeexpr.TypeString(returnType)
+		doc.Signature = fmt.Sprintf("%s(%s) %s", name, strings.Join(signature, ", "), doc.ReturnType)
+
+		docs = append(docs, doc)
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].Name < docs[j].Name
+	})
+	return docs, nil
+}
+
+func parameterDocs(ix int, parameter *proto.FunctionParameter) (ParameterDocs, error) {
+	parameterType, err := ctyjson.UnmarshalType(parameter.Type)
+	if err != nil {
+		return ParameterDocs{}, err
+	}
+
+	name := parameter.Name
+	if len(name) == 0 {
+		name = fmt.Sprintf("arg%d", ix)
+	}
+
+	return ParameterDocs{
+		Name:        name,
+		Type:        typeexpr.TypeString(parameterType),
+		Description: parameter.Description,
+		AllowNull:   parameter.AllowNull,
+	}, nil
+}
+
+func writeMarkdownDocs(w io.Writer, docs []FunctionDocs) error {
+	var out strings.Builder
+	out.WriteString("# Functions\n")
+
+	for _, doc := range docs {
+		fmt.Fprintf(&out, "\n## `%s`\n\n", doc.Name)
+		if doc.Deprecation != nil {
+			out.WriteString(markdownDeprecation(doc.Deprecation))
+		}
+		if len(doc.Description) > 0 {
+			fmt.Fprintf(&out, "%s\n\n", doc.Description)
+		}
+		fmt.Fprintf(&out, "```hcl\n%s\n```\n", doc.Signature)
+		if len(doc.Aliases) > 0 {
+			fmt.Fprintf(&out, "\nAlso available as `%s`.\n", strings.Join(doc.Aliases, "`, `"))
+		}
+
+		parameters := doc.Parameters
+		if doc.VariadicParameter != nil {
+			variadic := *doc.VariadicParameter
+			variadic.Name += "..."
+			parameters = append(parameters, variadic)
+		}
+		if len(parameters) > 0 {
+			out.WriteString("\n### Parameters\n\n")
+			out.WriteString("| Name | Type | Nullable | Description |\n")
+			out.WriteString("| ---- | ---- | -------- | ----------- |\n")
+			for _, parameter := range parameters {
+				fmt.Fprintf(&out, "| `%s` | `%s` | %t | %s |\n", parameter.Name, parameter.Type, parameter.AllowNull, markdownCell(parameter.Description))
+			}
+		}
+
+		fmt.Fprintf(&out, "\n### Returns\n\n`%s`\n", doc.ReturnType)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func markdownDeprecation(deprecation *Deprecation) string {
+	notice := "> **Deprecated.**"
+	if len(deprecation.Message) > 0 {
+		notice += " " + strings.TrimSuffix(deprecation.Message, ".") + "."
+	}
+	if len(deprecation.Replacement) > 0 {
+		notice += fmt.Sprintf(" Use `%s` instead.", deprecation.Replacement)
+	}
+	if len(deprecation.RemovalVersion) > 0 {
+		notice += fmt.Sprintf(" It will be removed in version %s.", deprecation.RemovalVersion)
+	}
+	return notice + "\n\n"
+}
+
+// markdownCell makes text safe to include within a markdown table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.ReplaceAll(text, "\n", " ")
+}
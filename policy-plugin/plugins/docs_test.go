@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDocs(t *testing.T) {
+	RegisterFunction("documented", func(s structure, count int, tags ...string) ([]string, error) {
+		return tags, nil
+	},
+		WithDescription("Returns the tags."),
+		WithParameters(
+			Parameter{Name: "input", Description: "The input object."},
+			Parameter{Name: "count"},
+			Parameter{Name: "tags", Description: "The tags | to return."}))
+
+	docs, err := functionDocs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var doc *FunctionDocs
+	for ix := range docs {
+		if docs[ix].Name == "documented" {
+			doc = &docs[ix]
+		}
+	}
+	if doc == nil {
+		t.Fatalf("expected docs for documented function")
+	}
+
+	expected := FunctionDocs{
+		Name:        "documented",
+		Signature:   "documented(input object({field=string}), count number, tags... string) list(string)",
+		Description: "Returns the tags.",
+		Parameters: []ParameterDocs{
+			{Name: "input", Type: "object({field=string})", Description: "The input object."},
+			{Name: "count", Type: "number"},
+		},
+		VariadicParameter: &ParameterDocs{Name: "tags", Type: "string", Description: "The tags | to return."},
+		ReturnType:        "list(string)",
+	}
+	if diff := cmp.Diff(expected, *doc); diff != "" {
+		t.Fatalf("unexpected docs (-want +got):\n%s", diff)
+	}
+
+	var markdown strings.Builder
+	if err := writeMarkdownDocs(&markdown, []FunctionDocs{*doc}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedMarkdown := "# Functions\n" +
+		"\n## `documented`\n\n" +
+		"Returns the tags.\n\n" +
+		"```hcl\ndocumented(input object({field=string}), count number, tags... string) list(string)\n```\n" +
+		"\n### Parameters\n\n" +
+		"| Name | Type | Nullable | Description |\n" +
+		"| ---- | ---- | -------- | ----------- |\n" +
+		"| `input` | `object({field=string})` | false | The input object. |\n" +
+		"| `count` | `number` | false |  |\n" +
+		"| `tags...` | `string` | false | The tags \\| to return. |\n" +
+		"\n### Returns\n\n`list(string)`\n"
+	if diff := cmp.Diff(expectedMarkdown, markdown.String()); diff != "" {
+		t.Fatalf("unexpected markdown (-want +got):\n%s", diff)
+	}
+}
@@ -4,43 +4,350 @@
 package plugins
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 
 	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins/convert"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
 )
 
+// NamespaceSeparator separates the namespace of a function from its name, as
+// in "aws::arn_parse".
+const NamespaceSeparator = "::"
+
 var (
-	functions map[string]function.Function
+	functions map[string]*registeredFunction
+
+	// aliases maps each alias to the name of the function it was registered
+	// for.
+	aliases map[string]string
 )
 
 func init() {
-	functions = make(map[string]function.Function)
+	functions = make(map[string]*registeredFunction)
+	aliases = make(map[string]string)
+}
+
+// registeredFunction is a function in the registry along with the options it
+// was registered with.
+type registeredFunction struct {
+	function.Function
+
+	name    string
+	aliases []string
+
+	// withContext returns a copy of the function that passes ctx to the
+	// underlying Go function, if it accepts a context.
+	withContext func(ctx context.Context) function.Function
+
+	// returnType computes the return type of the function from the types of
+	// its arguments, if it was registered with a dynamic return type.
+	returnType function.TypeFunc
+
+	cache *cache
+
+	description string
+	parameters  []Parameter
+	deprecation *Deprecation
+}
+
+// Parameter documents a parameter of a registered function.
+type Parameter struct {
+	Name        string
+	Description string
+}
+
+// Deprecation describes why a function is deprecated and what to use instead.
+type Deprecation struct {
+	Message string `json:"message"`
+
+	// Replacement is the name of the function to use instead, if any.
+	Replacement string `json:"replacement,omitempty"`
+
+	// RemovalVersion is the plugin version the function will be removed in, if
+	// known.
+	RemovalVersion string `json:"removal_version,omitempty"`
+}
+
+func (d *Deprecation) toProto() *proto.FunctionDeprecation {
+	return &proto.FunctionDeprecation{
+		Message:        d.Message,
+		Replacement:    d.Replacement,
+		RemovalVersion: d.RemovalVersion,
+	}
+}
+
+// FunctionOption configures optional behaviour of a registered function.
+type FunctionOption func(*registeredFunction)
+
+// WithCache memoises the results of the function, keyed by the function name
+// and its msgpack encoded arguments. It must only be used with pure functions
+// that always return the same result for the same arguments.
+func WithCache(options CacheOptions) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.cache = newCache(options)
+	}
+}
+
+// WithDescription sets the description of the function, overriding any
+// description set on a cty function.
+func WithDescription(description string) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.description = description
+	}
+}
+
+// WithParameters names and describes the parameters of the function, in order.
+// If the function is variadic, the last entry can describe the variadic
+// parameter. Parameters with an empty name keep the name of the underlying cty
+// parameter.
+func WithParameters(parameters ...Parameter) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.parameters = parameters
+	}
+}
+
+// WithDeprecation marks the function as deprecated. The function still works,
+// but every call returns a warning diagnostic so policy authors can move off it
+// before it is removed.
+func WithDeprecation(deprecation Deprecation) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.deprecation = &deprecation
+	}
 }
 
-// RegisterFunctionDirect registers a cty function with the given name.
-func RegisterFunctionDirect(name string, fn function.Function) {
+// WithReturnType computes the return type of the function from the types of the
+// arguments it is called with, for functions whose result depends on their
+// arguments, such as merging two objects. Hosts ask the plugin for the return
+// type before calling the function. Go functions registered with a dynamic
+// return type usually return a cty.Value, which is converted to the computed
+// type.
+func WithReturnType(returnType func(args []cty.Type) (cty.Type, error)) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.returnType = func(args []cty.Value) (cty.Type, error) {
+			types := make([]cty.Type, len(args))
+			for ix, arg := range args {
+				types[ix] = arg.Type()
+			}
+			return returnType(types)
+		}
+	}
+}
+
+// WithAliases registers additional names for the function, such as the names
+// it was known by before being renamed. Calls to an alias execute the
+// function, and ListFunctions reports the aliases alongside it. Registration
+// panics if an alias is given more than once, is the name of the function
+// itself, or is already registered.
+func WithAliases(names ...string) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.aliases = append(fn.aliases, names...)
+	}
+}
+
+// RegisterFunctionDirect registers a cty function with the given name. Names
+// can be namespaced, as in "aws::arn_parse", to avoid collisions between
+// plugins.
+func RegisterFunctionDirect(name string, fn function.Function, opts ...FunctionOption) {
+	register(name, fn, opts)
+}
+
+func register(name string, fn function.Function, opts []FunctionOption) *registeredFunction {
+	checkName(name)
+
+	registered := &registeredFunction{
+		Function: fn,
+		name:     name,
+	}
+	for _, opt := range opts {
+		opt(registered)
+	}
+
+	seen := make(map[string]bool, len(registered.aliases))
+	for _, alias := range registered.aliases {
+		if alias == name {
+			panic(fmt.Errorf("function %s can't be an alias of itself", name))
+		}
+		if seen[alias] {
+			panic(fmt.Errorf("alias %s is given more than once for function %s", alias, name))
+		}
+		seen[alias] = true
+		checkName(alias)
+	}
+
+	registered.Function = conforming(name, fn)
+	if registered.returnType != nil {
+		registered.Function = withReturnType(name, registered.Function, registered.returnType)
+	}
+
+	parameters := len(fn.Params())
+	if fn.VarParam() != nil {
+		parameters++
+	}
+	if len(registered.parameters) > parameters {
+		panic(fmt.Errorf("too many parameters documented for %s: expected at most %d", name, parameters))
+	}
+
+	functions[name] = registered
+	for _, alias := range registered.aliases {
+		aliases[alias] = name
+	}
+	return registered
+}
+
+// checkName panics if name is invalid or already registered as a function or
+// an alias.
+func checkName(name string) {
+	if err := validateName(name); err != nil {
+		panic(err)
+	}
 	if _, ok := functions[name]; ok {
 		panic("function already registered")
 	}
-	functions[name] = fn
+	if target, ok := aliases[name]; ok {
+		panic(fmt.Errorf("function %s already registered as an alias of %s", name, target))
+	}
+}
+
+// validateName checks that a possibly namespaced function name has no empty
+// parts.
+func validateName(name string) error {
+	for _, part := range strings.Split(name, NamespaceSeparator) {
+		if len(part) == 0 {
+			return fmt.Errorf("invalid function name %q: the name and any namespaces must not be empty", name)
+		}
+	}
+	return nil
+}
+
+// splitNamespace splits a function name into its namespace, which is empty
+// for functions without one, and its local name.
+func splitNamespace(name string) (string, string) {
+	ix := strings.LastIndex(name, NamespaceSeparator)
+	if ix < 0 {
+		return "", name
+	}
+	return name[:ix], name[ix+len(NamespaceSeparator):]
+}
+
+// lookupFunction returns the function registered with the given name or alias.
+func lookupFunction(name string) (*registeredFunction, bool) {
+	if target, ok := aliases[name]; ok {
+		name = target
+	}
+	fn, ok := functions[name]
+	return fn, ok
+}
+
+// call calls the function, making ctx available to functions that accept a
+// context.
+func (fn *registeredFunction) call(ctx context.Context, args []cty.Value) (cty.Value, error) {
+	if fn.withContext != nil {
+		withContext := conforming(fn.name, fn.withContext(ctx))
+		if fn.returnType != nil {
+			return withReturnType(fn.name, withContext, fn.returnType).Call(args)
+		}
+		return withContext.Call(args)
+	}
+	return fn.Call(args)
+}
+
+// withReturnType returns a copy of the named function fn with a different
+// return type.
+func withReturnType(name string, fn function.Function, returnType function.TypeFunc) function.Function {
+	return function.New(&function.Spec{
+		Description: fn.Description(),
+		Params:      fn.Params(),
+		VarParam:    fn.VarParam(),
+		Type:        returnType,
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			result, err := fn.Call(args)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			return conformResult(name, result, retType)
+		},
+	})
+}
+
+// describe converts the function into its protocol representation, including
+// any documentation provided at registration.
+func (fn *registeredFunction) describe() (*proto.Function, error) {
+	declared := fn.Function
+	if fn.returnType != nil {
+		// The return type depends on the arguments, so the host has to ask
+		// for it with GetReturnType.
+		declared = withReturnType(fn.name, declared, function.StaticReturnType(cty.DynamicPseudoType))
+	}
+
+	described, err := proto.FromCtyFunction(declared)
+	if err != nil {
+		return nil, err
+	}
+	described.DynamicReturnType = fn.returnType != nil
+
+	if len(fn.description) > 0 {
+		described.Description = fn.description
+	}
+
+	described.Namespace, _ = splitNamespace(fn.name)
+	described.Aliases = fn.aliases
+
+	if fn.deprecation != nil {
+		described.Deprecation = fn.deprecation.toProto()
+	}
+
+	for ix, parameter := range fn.parameters {
+		target := described.VariadicParameter
+		if ix < len(described.Parameters) {
+			target = described.Parameters[ix]
+		}
+
+		if len(parameter.Name) > 0 {
+			target.Name = parameter.Name
+		}
+		if len(parameter.Description) > 0 {
+			target.Description = parameter.Description
+		}
+	}
+	return described, nil
+}
+
+// warnings returns the diagnostics to include with every call to the function.
+func (fn *registeredFunction) warnings() []*proto.Diagnostic {
+	if fn.deprecation == nil {
+		return nil
+	}
+
+	return []*proto.Diagnostic{fn.deprecation.toProto().Diagnostic(fn.name)}
 }
 
 // CallFunction calls the function with the given name and arguments. This is
 // mainly used for testing.
 func CallFunction(name string, args ...cty.Value) (cty.Value, error) {
-	fn, ok := functions[name]
+	return CallFunctionContext(context.Background(), name, args...)
+}
+
+// CallFunctionContext calls the function with the given name and arguments,
+// passing ctx to functions that accept a context. This is mainly used for
+// testing.
+func CallFunctionContext(ctx context.Context, name string, args ...cty.Value) (cty.Value, error) {
+	fn, ok := lookupFunction(name)
 	if !ok {
 		return cty.NilVal, fmt.Errorf("function %s not found", name)
 	}
-	return fn.Call(args)
+	return fn.call(ctx, args)
 }
 
-// RegisterFunction registers a Go function with the given name.
-func RegisterFunction(name string, fn interface{}) {
+// RegisterFunction registers a Go function with the given name. The function
+// can optionally accept a context.Context as its first parameter, which will
+// carry values for the current call such as the logger returned by Logger.
+func RegisterFunction(name string, fn interface{}, opts ...FunctionOption) {
 	value := reflect.ValueOf(fn)
 	if value.Kind() != reflect.Func {
 		panic("fn must be a function")
@@ -53,20 +360,27 @@ func RegisterFunction(name string, fn interface{}) {
 		panic("second return value must be an error")
 	}
 
+	// offset is the number of Go parameters that come before the function
+	// arguments, which is one if the function accepts a context.
+	var offset int
+	if value.Type().NumIn() > 0 && value.Type().In(0) == reflect.TypeOf((*context.Context)(nil)).Elem() {
+		offset = 1
+	}
+
 	var args []function.Parameter
 	var variadic *function.Parameter
-	for ix := 0; ix < value.Type().NumIn(); ix++ {
+	for ix := offset; ix < value.Type().NumIn(); ix++ {
 
 		if value.Type().IsVariadic() && ix == value.Type().NumIn()-1 {
 			in := value.Type().In(ix)
 			param, err := convert.ToCtyType(in.Elem())
 			if err != nil {
-				panic(fmt.Errorf("invalid parameter %d for %s: %v", ix, name, err))
+				panic(fmt.Errorf("invalid parameter %d for %s: %v", ix-offset, name, err))
 			}
 
 			variadic = &function.Parameter{
 				Type:      param,
-				AllowNull: in.Kind() == reflect.Pointer || param.IsCollectionType(),
+				AllowNull: in.Kind() == reflect.Pointer || param.IsCollectionType() || param == cty.DynamicPseudoType,
 			}
 			continue
 		}
@@ -74,12 +388,12 @@ func RegisterFunction(name string, fn interface{}) {
 		in := value.Type().In(ix)
 		param, err := convert.ToCtyType(in)
 		if err != nil {
-			panic(fmt.Errorf("invalid parameter %d for %s: %v", ix, name, err))
+			panic(fmt.Errorf("invalid parameter %d for %s: %v", ix-offset, name, err))
 		}
 
 		args = append(args, function.Parameter{
 			Type:      param,
-			AllowNull: in.Kind() == reflect.Pointer || param.IsCollectionType(),
+			AllowNull: in.Kind() == reflect.Pointer || param.IsCollectionType() || param == cty.DynamicPseudoType,
 		})
 	}
 
@@ -88,42 +402,54 @@ func RegisterFunction(name string, fn interface{}) {
 		panic(fmt.Errorf("invalid return type: %v", err))
 	}
 
-	RegisterFunctionDirect(name, function.New(&function.Spec{
-		Params:   args,
-		VarParam: variadic,
-		Type:     function.StaticReturnType(returnType),
-		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+	spec := func(ctx context.Context) *function.Spec {
+		return &function.Spec{
+			Params:   args,
+			VarParam: variadic,
+			Type:     function.StaticReturnType(returnType),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
 
-			var arguments []reflect.Value
-			for i, arg := range args {
-				if value.Type().IsVariadic() && i >= value.Type().NumIn()-1 {
-					want := value.Type().In(value.Type().NumIn() - 1)
-					argument, err := convert.FromCtyValue(arg, want.Elem())
+				var arguments []reflect.Value
+				if offset > 0 {
+					arguments = append(arguments, reflect.ValueOf(ctx))
+				}
+				for i, arg := range args {
+					if value.Type().IsVariadic() && i+offset >= value.Type().NumIn()-1 {
+						want := value.Type().In(value.Type().NumIn() - 1)
+						argument, err := convert.FromCtyValue(arg, want.Elem())
+						if err != nil {
+							return cty.NullVal(returnType), fmt.Errorf("failed to convert variadic argument %d: %w", i, err)
+						}
+						arguments = append(arguments, argument)
+						continue
+					}
+
+					want := value.Type().In(i + offset)
+					argument, err := convert.FromCtyValue(arg, want)
 					if err != nil {
-						return cty.NullVal(returnType), fmt.Errorf("failed to convert variadic argument %d: %w", i, err)
+						return cty.NullVal(returnType), fmt.Errorf("failed to convert argument %d: %w", i, err)
 					}
 					arguments = append(arguments, argument)
-					continue
 				}
 
-				want := value.Type().In(i)
-				argument, err := convert.FromCtyValue(arg, want)
-				if err != nil {
-					return cty.NullVal(returnType), fmt.Errorf("failed to convert argument %d: %w", i, err)
+				results := value.Call(arguments)
+				if err := results[1].Interface(); err != nil {
+					return cty.NilVal, err.(error)
 				}
-				arguments = append(arguments, argument)
-			}
 
-			results := value.Call(arguments)
-			if err := results[1].Interface(); err != nil {
-				return cty.NilVal, err.(error)
-			}
+				value, err := convert.ToCtyValue(results[0], returnType)
+				if err != nil {
+					return cty.NilVal, resultError(name, err)
+				}
+				return value, nil
+			},
+		}
+	}
 
-			value, err := convert.ToCtyValue(results[0], returnType)
-			if err != nil {
-				return cty.NilVal, fmt.Errorf("failed to convert result: %w", err)
-			}
-			return value, nil
-		},
-	}))
+	registered := register(name, function.New(spec(context.Background())), opts)
+	if offset > 0 {
+		registered.withContext = func(ctx context.Context) function.Function {
+			return function.New(spec(ctx))
+		}
+	}
 }
@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// pluginServiceName is the full name of the Plugin gRPC service.
+const pluginServiceName = "protocol.Plugin"
+
+// healthWatchInterval is how often the health service re-evaluates the status
+// of the plugin for clients watching it.
+var healthWatchInterval = time.Second
+
+var (
+	readinessChecks     map[string]func(ctx context.Context) error
+	readinessCheckNames []string
+
+	_ grpc_health_v1.HealthServer = (*healthServer)(nil)
+)
+
+func init() {
+	readinessChecks = make(map[string]func(ctx context.Context) error)
+}
+
+// RegisterReadinessCheck registers a check that must pass before the health
+// service reports the plugin as serving, such as making sure a data file was
+// loaded. Checks run every time a host checks the health of the plugin, and
+// each can also be checked on its own using its name as the service name.
+func RegisterReadinessCheck(name string, check func(ctx context.Context) error) {
+	if _, ok := readinessChecks[name]; ok {
+		panic("readiness check already registered")
+	}
+	readinessChecks[name] = check
+	readinessCheckNames = append(readinessCheckNames, name)
+}
+
+// healthServer implements the standard gRPC health service. The overall
+// status, and the status of the Plugin service, is serving once the plugin has
+// been set up, until it starts shutting down, as long as every readiness check
+// passes.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+
+	lifecycle *lifecycle
+}
+
+func newHealthServer(lifecycle *lifecycle) *healthServer {
+	return &healthServer{
+		lifecycle: lifecycle,
+	}
+}
+
+func (h *healthServer) Check(ctx context.Context, request *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	serving, err := h.status(ctx, request.Service)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc_health_v1.HealthCheckResponse{
+		Status: serving,
+	}, nil
+}
+
+func (h *healthServer) Watch(request *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	ctx := stream.Context()
+
+	last := grpc_health_v1.HealthCheckResponse_UNKNOWN
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		serving, err := h.status(ctx, request.Service)
+		if status.Code(err) == codes.NotFound {
+			// The standard health service reports unknown services as such
+			// when watching, instead of failing.
+			serving, err = grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if serving != last {
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: serving}); err != nil {
+				return err
+			}
+			last = serving
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// status returns the status of the named service.
+func (h *healthServer) status(ctx context.Context, service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	switch service {
+	case plugin.GRPCServiceName:
+		// go-plugin uses this service to check the connection to the plugin
+		// is alive, regardless of whether it is ready.
+		return grpc_health_v1.HealthCheckResponse_SERVING, nil
+	case "", pluginServiceName:
+		if !h.lifecycle.serving() {
+			return grpc_health_v1.HealthCheckResponse_NOT_SERVING, nil
+		}
+		for _, name := range readinessCheckNames {
+			if err := readinessChecks[name](ctx); err != nil {
+				return grpc_health_v1.HealthCheckResponse_NOT_SERVING, nil
+			}
+		}
+		return grpc_health_v1.HealthCheckResponse_SERVING, nil
+	}
+
+	check, ok := readinessChecks[service]
+	if !ok {
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN, status.Error(codes.NotFound, fmt.Sprintf("unknown service %q", service))
+	}
+	if err := check(ctx); err != nil {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING, nil
+	}
+	return grpc_health_v1.HealthCheckResponse_SERVING, nil
+}
+
+// grpcServer creates the gRPC server for go-plugin. go-plugin registers its
+// own health service before the plugin can, so the server routes health checks
+// to the plugin's health service instead.
+func (h *healthServer) grpcServer(opts []grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if info.FullMethod == grpc_health_v1.Health_Check_FullMethodName {
+				return h.Check(ctx, req.(*grpc_health_v1.HealthCheckRequest))
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if info.FullMethod == grpc_health_v1.Health_Watch_FullMethodName {
+				request := new(grpc_health_v1.HealthCheckRequest)
+				if err := stream.RecvMsg(request); err != nil {
+					return err
+				}
+				return h.Watch(request, &grpc.GenericServerStream[grpc_health_v1.HealthCheckRequest, grpc_health_v1.HealthCheckResponse]{ServerStream: stream})
+			}
+			return handler(srv, stream)
+		}))
+	return grpc.NewServer(opts...)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestHealth(t *testing.T) {
+	defer func(checks map[string]func(context.Context) error, names []string) {
+		readinessChecks, readinessCheckNames = checks, names
+	}(readinessChecks, readinessCheckNames)
+	readinessChecks = make(map[string]func(context.Context) error)
+	readinessCheckNames = nil
+
+	var loadErr error
+	RegisterReadinessCheck("data file loaded", func(context.Context) error {
+		return loadErr
+	})
+
+	config := newServeConfig(nil)
+	server := &GrpcServer{lifecycle: config.lifecycle}
+	health := config.health
+
+	check := func(service string, expected grpc_health_v1.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+
+		response, err := health.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if response.Status != expected {
+			t.Errorf("expected %q to be %s, got %s", service, expected, response.Status)
+		}
+	}
+
+	check("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	check("protocol.Plugin", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	check("plugin", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	if _, err := server.Setup(context.Background(), new(proto.PluginSetupRequest)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check("", grpc_health_v1.HealthCheckResponse_SERVING)
+	check("protocol.Plugin", grpc_health_v1.HealthCheckResponse_SERVING)
+	check("data file loaded", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	loadErr = errors.New("missing data file")
+	check("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	check("data file loaded", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	loadErr = nil
+	if _, err := server.Stop(context.Background(), new(proto.StopRequest)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	check("plugin", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	if _, err := health.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected unknown services to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// ErrNoHost is returned by the Host of functions executed by a host that
+// doesn't serve callbacks to the plugin.
+var ErrNoHost = errors.New("the host doesn't serve callbacks to the plugin")
+
+var (
+	_ Host = (*grpcHost)(nil)
+	_ Host = noHost{}
+)
+
+// Host is the API functions use to query the policy runtime that executes
+// them.
+type Host interface {
+	// GetResource looks up a resource by its address, and returns false if
+	// there is no such resource.
+	GetResource(ctx context.Context, address string) (cty.Value, bool, error)
+
+	// GetVariable reads the value of a variable, and returns false if there is
+	// no such variable.
+	GetVariable(ctx context.Context, name string) (cty.Value, bool, error)
+
+	// Log writes a message to the policy output.
+	Log(ctx context.Context, level hclog.Level, message string) error
+}
+
+// Call identifies a single function call.
+type Call struct {
+	Function string
+	ID       string
+}
+
+type hostKey struct{}
+
+type callKey struct{}
+
+// HostFromContext returns the host that is executing the current function
+// call. Functions must accept a context to call the host. If the host doesn't
+// serve callbacks, every method returns ErrNoHost.
+func HostFromContext(ctx context.Context) Host {
+	if host, ok := ctx.Value(hostKey{}).(Host); ok {
+		return host
+	}
+	return noHost{}
+}
+
+// ContextWithHost returns a copy of ctx that carries host. The framework calls
+// this for every function execution, and tests can use it together with
+// CallFunctionContext to provide a fake host.
+func ContextWithHost(ctx context.Context, host Host) context.Context {
+	return context.WithValue(ctx, hostKey{}, host)
+}
+
+// CallFromContext returns the function call ctx belongs to, if any.
+func CallFromContext(ctx context.Context) (Call, bool) {
+	call, ok := ctx.Value(callKey{}).(Call)
+	return call, ok
+}
+
+// ContextWithCall returns a copy of ctx that identifies the function call.
+func ContextWithCall(ctx context.Context, call Call) context.Context {
+	return context.WithValue(ctx, callKey{}, call)
+}
+
+// grpcHost calls the Host service the client serves through the go-plugin
+// broker.
+type grpcHost struct {
+	client proto.HostClient
+}
+
+func (h *grpcHost) GetResource(ctx context.Context, address string) (cty.Value, bool, error) {
+	response, err := h.client.GetResource(ctx, &proto.GetResourceRequest{
+		Address: address,
+	})
+	if err != nil {
+		return cty.NilVal, false, err
+	}
+	return decodeHostValue(response.Found, response.Type, response.Value)
+}
+
+func (h *grpcHost) GetVariable(ctx context.Context, name string) (cty.Value, bool, error) {
+	response, err := h.client.GetVariable(ctx, &proto.GetVariableRequest{
+		Name: name,
+	})
+	if err != nil {
+		return cty.NilVal, false, err
+	}
+	return decodeHostValue(response.Found, response.Type, response.Value)
+}
+
+func (h *grpcHost) Log(ctx context.Context, level hclog.Level, message string) error {
+	call, _ := CallFromContext(ctx)
+	_, err := h.client.Log(ctx, &proto.HostLogRequest{
+		Level:    level.String(),
+		Message:  message,
+		Function: call.Function,
+		CallId:   call.ID,
+	})
+	return err
+}
+
+// newGrpcHost returns the host for the client, which also provides functions if
+// the client advertises it.
+func newGrpcHost(client proto.HostClient, capabilities *proto.PluginSetupRequest_ClientCapabilities) Host {
+	host := &grpcHost{client: client}
+	if capabilities.GetCallFunction() {
+		return &grpcFunctionHost{grpcHost: host}
+	}
+	return host
+}
+
+// encodeDynamicValue encodes a value together with its type.
+func encodeDynamicValue(value cty.Value) (*proto.DynamicValue, error) {
+	valueType, err := ctyjson.MarshalType(value.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := msgpack.Marshal(value, value.Type())
+	if err != nil {
+		return nil, err
+	}
+	return &proto.DynamicValue{
+		Type:  valueType,
+		Value: encoded,
+	}, nil
+}
+
+func decodeHostValue(found bool, rawType, rawValue []byte) (cty.Value, bool, error) {
+	if !found {
+		return cty.NilVal, false, nil
+	}
+
+	valueType, err := ctyjson.UnmarshalType(rawType)
+	if err != nil {
+		return cty.NilVal, false, err
+	}
+
+	value, err := msgpack.Unmarshal(rawValue, valueType)
+	if err != nil {
+		return cty.NilVal, false, err
+	}
+	return value, true, nil
+}
+
+// noHost is the host of functions executed without a host serving callbacks.
+type noHost struct{}
+
+func (noHost) GetResource(context.Context, string) (cty.Value, bool, error) {
+	return cty.NilVal, false, ErrNoHost
+}
+
+func (noHost) GetVariable(context.Context, string) (cty.Value, bool, error) {
+	return cty.NilVal, false, ErrNoHost
+}
+
+func (noHost) Log(context.Context, hclog.Level, string) error {
+	return ErrNoHost
+}
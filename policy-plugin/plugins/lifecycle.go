@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// shutdownTimeout limits how long Stop waits for function executions when the
+// host doesn't set a deadline, and how long the shutdown hooks can run for when
+// the plugin exits without the host calling Stop first.
+var shutdownTimeout = 10 * time.Second
+
+var (
+	setupHooks    []func(ctx context.Context) error
+	shutdownHooks []func(ctx context.Context) error
+)
+
+// OnSetup registers a function to run when the host sets up the plugin, before
+// any functions are executed. It is useful for acquiring resources that
+// functions share, such as clients or temporary directories. If a hook returns
+// an error, the host fails to set up the plugin.
+func OnSetup(hook func(ctx context.Context) error) {
+	setupHooks = append(setupHooks, hook)
+}
+
+// OnShutdown registers a function to run when the plugin stops, after every
+// function execution has finished. It is useful for releasing the resources
+// acquired by OnSetup hooks. Shutdown hooks run in the reverse order they were
+// registered in.
+//
+// If the executions don't finish before the deadline of the host's Stop call,
+// or within ten seconds if it has none, the hooks run while they are still in
+// progress and the host is warned about it. The hooks then get a context with
+// another ten seconds to release resources.
+func OnShutdown(hook func(ctx context.Context) error) {
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
+// lifecycle tracks the function executions in progress, so the plugin can stop
+// gracefully, and makes sure the hooks run only once.
+type lifecycle struct {
+	mutex    sync.Mutex
+	ready    bool
+	stopping bool
+	running  int
+	calls    sync.WaitGroup
+
+	setupOnce sync.Once
+	setupErr  error
+
+	shutdownOnce  sync.Once
+	shutdownDiags []*proto.Diagnostic
+}
+
+// setup runs the setup hooks the first time it is called.
+func (l *lifecycle) setup(ctx context.Context) error {
+	l.setupOnce.Do(func() {
+		for _, hook := range setupHooks {
+			if err := hook(ctx); err != nil {
+				l.setupErr = fmt.Errorf("setup hook failed: %w", err)
+				return
+			}
+		}
+
+		l.mutex.Lock()
+		l.ready = true
+		l.mutex.Unlock()
+	})
+	return l.setupErr
+}
+
+// serving returns true if the plugin has been set up and isn't stopping.
+func (l *lifecycle) serving() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.ready && !l.stopping
+}
+
+// begin records the start of a function execution, and returns false if the
+// plugin is stopping and the execution must be rejected.
+func (l *lifecycle) begin() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.stopping {
+		return false
+	}
+	l.running++
+	l.calls.Add(1)
+	return true
+}
+
+// end records the end of a function execution started with begin.
+func (l *lifecycle) end() {
+	l.mutex.Lock()
+	l.running--
+	l.mutex.Unlock()
+
+	l.calls.Done()
+}
+
+// stop rejects new function executions, waits until the executions in progress
+// finish or ctx is done, and then runs the shutdown hooks. Without a deadline
+// on ctx, it waits for shutdownTimeout at most. If the wait times out, the hooks
+// get another shutdownTimeout to run.
+func (l *lifecycle) stop(ctx context.Context) []*proto.Diagnostic {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+	}
+
+	l.mutex.Lock()
+	l.stopping = true
+	l.mutex.Unlock()
+
+	var diags []*proto.Diagnostic
+
+	done := make(chan struct{})
+	go func() {
+		l.calls.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		l.mutex.Lock()
+		running := l.running
+		l.mutex.Unlock()
+
+		diags = append(diags, &proto.Diagnostic{
+			Severity: proto.Diagnostic_WARNING,
+			Summary:  "Function executions still running",
+			Detail:   fmt.Sprintf("The plugin stopped before every function execution finished: %s. The shutdown hooks ran while %d executions were still in progress.", ctx.Err(), running),
+		})
+
+		// The hooks still need time to release resources, so they get a
+		// context of their own instead of the expired one.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+	}
+
+	return append(diags, l.shutdown(ctx)...)
+}
+
+// shutdown runs the shutdown hooks the first time it is called.
+func (l *lifecycle) shutdown(ctx context.Context) []*proto.Diagnostic {
+	l.shutdownOnce.Do(func() {
+		for ix := len(shutdownHooks) - 1; ix >= 0; ix-- {
+			if err := shutdownHooks[ix](ctx); err != nil {
+				l.shutdownDiags = append(l.shutdownDiags, &proto.Diagnostic{
+					Severity: proto.Diagnostic_ERROR,
+					Summary:  "Shutdown hook failed",
+					Detail:   err.Error(),
+				})
+			}
+		}
+	})
+	return l.shutdownDiags
+}
+
+// shutdownAfterServe runs the shutdown hooks once the plugin stops serving, in
+// case the host didn't call Stop.
+func (config *serveConfig) shutdownAfterServe() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, diag := range config.lifecycle.shutdown(ctx) {
+		config.logger.Error(diag.Summary, "error", diag.Detail)
+	}
+}
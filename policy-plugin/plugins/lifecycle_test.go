@@ -0,0 +1,159 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestLifecycle(t *testing.T) {
+	defer func(setup, shutdown []func(context.Context) error) {
+		setupHooks, shutdownHooks = setup, shutdown
+	}(setupHooks, shutdownHooks)
+
+	var events []string
+	OnSetup(func(context.Context) error {
+		events = append(events, "setup")
+		return nil
+	})
+	OnShutdown(func(context.Context) error {
+		events = append(events, "shutdown first")
+		return nil
+	})
+	OnShutdown(func(context.Context) error {
+		events = append(events, "shutdown second")
+		return errors.New("failed to clean up")
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	RegisterFunction("blocking", func() (string, error) {
+		close(started)
+		<-release
+		events = append(events, "executed")
+		return "done", nil
+	})
+
+	server := new(GrpcServer)
+	for i := 0; i < 2; i++ {
+		if _, err := server.Setup(context.Background(), new(proto.PluginSetupRequest)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	executed := make(chan *proto.ExecuteFunctionResponse)
+	go func() {
+		response, _ := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{Name: "blocking"})
+		executed <- response
+	}()
+	<-started
+
+	stopped := make(chan *proto.StopResponse)
+	go func() {
+		response, _ := server.Stop(context.Background(), new(proto.StopRequest))
+		stopped <- response
+	}()
+
+	// Wait for the server to start stopping, then check new executions are
+	// rejected.
+	for {
+		server.lifecycle.mutex.Lock()
+		stopping := server.lifecycle.stopping
+		server.lifecycle.mutex.Unlock()
+		if stopping {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	rejected, err := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{Name: "blocking"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rejected.Diagnostics) != 1 || rejected.Diagnostics[0].Summary != "Plugin unavailable" {
+		t.Errorf("expected the execution to be rejected, got %v", rejected.Diagnostics)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatalf("expected Stop to wait for the execution in progress")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	if response := <-executed; len(response.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", response.Diagnostics)
+	}
+
+	response := <-stopped
+	if len(response.Diagnostics) != 1 || response.Diagnostics[0].Detail != "failed to clean up" {
+		t.Errorf("expected the failed hook to be reported, got %v", response.Diagnostics)
+	}
+
+	if diff := cmp.Diff([]string{"setup", "executed", "shutdown second", "shutdown first"}, events); diff != "" {
+		t.Errorf("unexpected events (-want +got):\n%s", diff)
+	}
+}
+
+func TestLifecycle_StopDeadline(t *testing.T) {
+	defer func(shutdown []func(context.Context) error) {
+		shutdownHooks = shutdown
+	}(shutdownHooks)
+
+	var hookErr error
+	OnShutdown(func(ctx context.Context) error {
+		hookErr = ctx.Err()
+		return hookErr
+	})
+
+	lifecycle := new(lifecycle)
+	if !lifecycle.begin() {
+		t.Fatalf("expected execution to begin")
+	}
+	defer lifecycle.end()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	diags := lifecycle.stop(ctx)
+	if len(diags) != 1 || diags[0].Severity != proto.Diagnostic_WARNING || !strings.Contains(diags[0].Detail, "1 executions") {
+		t.Errorf("expected a warning about the execution still running, got %v", diags)
+	}
+	if hookErr != nil {
+		t.Errorf("expected the shutdown hook to get a live context, got %s", hookErr)
+	}
+}
+
+func TestLifecycle_StopWithoutDeadline(t *testing.T) {
+	timeout := shutdownTimeout
+	shutdownTimeout = 10 * time.Millisecond
+	defer func() { shutdownTimeout = timeout }()
+
+	lifecycle := new(lifecycle)
+	if !lifecycle.begin() {
+		t.Fatalf("expected execution to begin")
+	}
+	defer lifecycle.end()
+
+	done := make(chan []*proto.Diagnostic)
+	go func() {
+		done <- lifecycle.stop(context.Background())
+	}()
+
+	select {
+	case diags := <-done:
+		if len(diags) != 1 || diags[0].Severity != proto.Diagnostic_WARNING {
+			t.Errorf("expected a warning about the execution still running, got %v", diags)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected stop to time out without a deadline")
+	}
+}
@@ -4,6 +4,7 @@
 package plugins
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -24,3 +25,23 @@ func logLevel(plugin string) hclog.Level {
 	}
 	return level
 }
+
+type loggerKey struct{}
+
+// Logger returns the logger for the current function call from the context
+// passed to functions that accept a context. Records written to it are tagged
+// with the function name and call ID, written to the logger given to Serve and
+// streamed to the host. If there is no logger in the context, the returned
+// logger discards everything.
+func Logger(ctx context.Context) hclog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(hclog.Logger); ok {
+		return logger
+	}
+	return hclog.NewNullLogger()
+}
+
+// ContextWithLogger returns a copy of ctx that carries logger, so it is
+// returned by Logger. This is mainly used for testing functions that log.
+func ContextWithLogger(ctx context.Context, logger hclog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
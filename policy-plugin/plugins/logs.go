@@ -0,0 +1,166 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// logBufferSize is the number of records buffered for each client streaming
+// logs. Records are dropped rather than blocking function execution if a
+// client falls this far behind.
+const logBufferSize = 256
+
+var (
+	_ hclog.SinkAdapter = (*logHub)(nil)
+)
+
+// logHub forwards the records written by the per-call loggers to the framework
+// logger and to every client streaming logs.
+type logHub struct {
+	logger    hclog.Logger
+	intercept hclog.InterceptLogger
+
+	mutex       sync.Mutex
+	subscribers map[*logSubscriber]struct{}
+}
+
+type logSubscriber struct {
+	level   hclog.Level
+	records chan *proto.LogRecord
+	dropped uint64
+}
+
+func newLogHub(logger hclog.Logger) *logHub {
+	hub := &logHub{
+		logger: logger,
+		// The intercept logger only exists to pass records to the hub, which
+		// writes them to the real logger.
+		intercept: hclog.NewInterceptLogger(&hclog.LoggerOptions{
+			Level:  hclog.Trace,
+			Output: io.Discard,
+		}),
+		subscribers: make(map[*logSubscriber]struct{}),
+	}
+	hub.intercept.RegisterSink(hub)
+	return hub
+}
+
+// callLogger returns the logger for a single function call.
+func (h *logHub) callLogger(function, callID string) hclog.Logger {
+	return h.intercept.With("function", function, "call_id", callID)
+}
+
+// subscribe returns a channel that receives every record at or above level,
+// and a function that must be called to stop receiving them.
+func (h *logHub) subscribe(level hclog.Level) (<-chan *proto.LogRecord, func()) {
+	subscriber := &logSubscriber{
+		level:   level,
+		records: make(chan *proto.LogRecord, logBufferSize),
+	}
+
+	h.mutex.Lock()
+	h.subscribers[subscriber] = struct{}{}
+	h.mutex.Unlock()
+
+	return subscriber.records, func() {
+		h.mutex.Lock()
+		delete(h.subscribers, subscriber)
+		h.mutex.Unlock()
+
+		if subscriber.dropped > 0 {
+			h.logger.Warn("dropped log records for slow client", "dropped", subscriber.dropped)
+		}
+	}
+}
+
+// Accept implements hclog.SinkAdapter.
+func (h *logHub) Accept(_ string, level hclog.Level, msg string, args ...interface{}) {
+	h.logger.Log(level, msg, args...)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.subscribers) == 0 {
+		return
+	}
+
+	record := newLogRecord(level, msg, args)
+	for subscriber := range h.subscribers {
+		if level < subscriber.level {
+			continue
+		}
+
+		select {
+		case subscriber.records <- record:
+		default:
+			subscriber.dropped++
+		}
+	}
+}
+
+func newLogRecord(level hclog.Level, msg string, args []interface{}) *proto.LogRecord {
+	record := &proto.LogRecord{
+		Level:     level.String(),
+		Message:   msg,
+		Timestamp: timestamppb.Now(),
+	}
+
+	fields := make(map[string]interface{})
+	for i := 0; i < len(args); i += 2 {
+		key := fmt.Sprint(args[i])
+		if i+1 == len(args) {
+			// hclog uses the same key for an odd number of arguments.
+			fields["EXTRA_VALUE_AT_END"] = args[i]
+			break
+		}
+
+		value := args[i+1]
+		switch key {
+		case "function":
+			record.Function = fmt.Sprint(value)
+			continue
+		case "call_id":
+			record.CallId = fmt.Sprint(value)
+			continue
+		}
+
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		fields[key] = value
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		// Fall back to the string representation of anything that can't be
+		// represented in JSON.
+		for key, value := range fields {
+			fields[key] = fmt.Sprint(value)
+		}
+		data, _ = json.Marshal(fields)
+	}
+	record.Fields = data
+	return record
+}
+
+// newCallID generates a random ID for calls that the client didn't provide an
+// ID for.
+func newCallID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestExecuteFunction_Logs(t *testing.T) {
+	RegisterFunction("logging", func(ctx context.Context, s string, rest ...string) (string, error) {
+		Logger(ctx).Debug("filtered out")
+		Logger(ctx).Info("joining", "count", len(rest)+1, "error", errors.New("not really"))
+		return strings.Join(append([]string{s}, rest...), ","), nil
+	})
+
+	server := new(GrpcServer)
+	server.init()
+	records, unsubscribe := server.logs.subscribe(hclog.Info)
+	defer unsubscribe()
+
+	var arguments [][]byte
+	for _, value := range []string{"a", "b", "c"} {
+		argument, err := msgpack.Marshal(cty.StringVal(value), cty.String)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arguments = append(arguments, argument)
+	}
+
+	response, err := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+		Name:      "logging",
+		Arguments: arguments,
+		CallId:    "call-1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if response.CallId != "call-1" {
+		t.Errorf("expected call ID call-1, got %q", response.CallId)
+	}
+
+	result, err := msgpack.Unmarshal(response.Result, cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.RawEquals(cty.StringVal("a,b,c")) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expected exactly one record, got %d", len(records))
+	}
+	record := <-records
+	if record.CallId != "call-1" || record.Function != "logging" || record.Level != "info" || record.Message != "joining" {
+		t.Errorf("unexpected record: %v", record)
+	}
+	if fields := string(record.Fields); fields != `{"count":3,"error":"not really"}` {
+		t.Errorf("unexpected fields: %s", fields)
+	}
+}
+
+func TestExecuteFunction_GeneratesCallID(t *testing.T) {
+	RegisterFunction("noCallID", func() (string, error) {
+		return "", nil
+	})
+
+	response, err := new(GrpcServer).ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+		Name: "noCallID",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(response.CallId) == 0 {
+		t.Errorf("expected a generated call ID")
+	}
+}
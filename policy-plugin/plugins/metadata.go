@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/mod/semver"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// frameworkModule is the module path of this framework, used to find its
+// version in the build info of the plugin binary.
+const frameworkModule = "github.com/hashicorp/terraform-policy-plugin-framework"
+
+// WithName sets the name the plugin reports through the GetMetadata RPC.
+func WithName(name string) ServeOption {
+	return func(config *serveConfig) {
+		config.name = name
+	}
+}
+
+// WithVersion sets the semantic version the plugin reports through the
+// GetMetadata RPC, such as "1.2.0". It panics if version is not a valid
+// semantic version with a major, minor and patch version.
+func WithVersion(version string) ServeOption {
+	// semver accepts shorthands such as "v1.2", which Canonical expands.
+	v := "v" + strings.TrimPrefix(version, "v")
+	if !semver.IsValid(v) || semver.Canonical(v)+semver.Build(v) != v {
+		panic(fmt.Errorf("invalid plugin version %q: must be a semantic version such as 1.2.0", version))
+	}
+	return func(config *serveConfig) {
+		config.version = version
+	}
+}
+
+// readBuildInfo returns the framework version and the build info of the plugin
+// binary, if it was built with module support.
+func readBuildInfo() (string, *proto.BuildInfo) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", nil
+	}
+
+	frameworkVersion := ""
+	if info.Main.Path == frameworkModule {
+		frameworkVersion = info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != frameworkModule {
+			continue
+		}
+		frameworkVersion = dep.Version
+		if dep.Replace != nil {
+			frameworkVersion = dep.Replace.Version
+		}
+	}
+
+	settings := make(map[string]string, len(info.Settings))
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+
+	return frameworkVersion, &proto.BuildInfo{
+		GoVersion:   info.GoVersion,
+		Path:        info.Path,
+		MainVersion: info.Main.Version,
+		Settings:    settings,
+	}
+}
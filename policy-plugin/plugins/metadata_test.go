@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestGetMetadata(t *testing.T) {
+	config := newServeConfig([]ServeOption{WithName("example"), WithVersion("0.3.0-beta.1")})
+	server := &GrpcServer{name: config.name, version: config.version}
+
+	metadata, err := server.GetMetadata(context.Background(), new(proto.GetMetadataRequest))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if metadata.Name != "example" || metadata.Version != "0.3.0-beta.1" {
+		t.Errorf("unexpected name and version: %v", metadata)
+	}
+	if metadata.ProtocolVersion != LatestProtocolVersion {
+		t.Errorf("expected protocol version %d, got %d", LatestProtocolVersion, metadata.ProtocolVersion)
+	}
+	if metadata.BuildInfo == nil || len(metadata.BuildInfo.GoVersion) == 0 {
+		t.Errorf("expected build info, got %v", metadata.BuildInfo)
+	}
+}
+
+func TestWithVersion(t *testing.T) {
+	tcs := map[string]bool{
+		"1.2.0":         true,
+		"v1.2.0":        true,
+		"1.2.0-beta.1":  true,
+		"1.2.0+build.5": true,
+		"1":             false,
+		"1.2":           false,
+		"v1.2":          false,
+		"01.2.0":        false,
+		"one point two": false,
+	}
+	for version, valid := range tcs {
+		t.Run(version, func(t *testing.T) {
+			defer func() {
+				if panicked := recover() != nil; panicked == valid {
+					t.Errorf("expected WithVersion(%q) to panic: %t", version, !valid)
+				}
+			}()
+			WithVersion(version)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The categories of errors counted by the function errors metric.
+const (
+	errorNotFound         = "not_found"
+	errorInvalidArguments = "invalid_arguments"
+	errorFunction         = "function"
+	errorResult           = "result"
+	errorPanic            = "panic"
+	errorUnavailable      = "unavailable"
+)
+
+// unknownFunction is the function label of calls to functions that aren't
+// registered, so names sent by the host can't create new series.
+const unknownFunction = "<unknown>"
+
+// errorSummaries are the summaries of the diagnostics reported for each
+// category of error.
+var errorSummaries = map[string]string{
+	errorNotFound:         "Function not found",
+	errorInvalidArguments: "Invalid function arguments",
+	errorFunction:         "Error in function call",
+	errorResult:           "Invalid function result",
+	errorUnavailable:      "Plugin unavailable",
+}
+
+var (
+	durationBuckets     = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+	argumentSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+)
+
+// metrics records statistics about function executions, and renders them in
+// the Prometheus text exposition format.
+type metrics struct {
+	mutex     sync.Mutex
+	functions map[string]*functionMetrics
+}
+
+type functionMetrics struct {
+	calls         uint64
+	errors        map[string]uint64
+	duration      *histogram
+	argumentBytes *histogram
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		functions: make(map[string]*functionMetrics),
+	}
+}
+
+// observe records a single execution of the named function. category is empty
+// if the execution succeeded.
+func (m *metrics) observe(name string, duration time.Duration, argumentBytes int, category string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	fn, ok := m.functions[name]
+	if !ok {
+		fn = &functionMetrics{
+			errors:        make(map[string]uint64),
+			duration:      newHistogram(durationBuckets),
+			argumentBytes: newHistogram(argumentSizeBuckets),
+		}
+		m.functions[name] = fn
+	}
+
+	fn.calls++
+	if len(category) > 0 {
+		fn.errors[category]++
+	}
+	fn.duration.observe(duration.Seconds())
+	fn.argumentBytes.observe(float64(argumentBytes))
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+func (m *metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var names []string
+	for name := range m.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+
+	writeHeader(&out, "tfpolicy_plugin_function_calls_total", "counter", "Total number of function executions.")
+	for _, name := range names {
+		fmt.Fprintf(&out, "tfpolicy_plugin_function_calls_total{function=%s} %d\n", labelValue(name), m.functions[name].calls)
+	}
+
+	writeHeader(&out, "tfpolicy_plugin_function_errors_total", "counter", "Total number of failed function executions, by category.")
+	for _, name := range names {
+		var categories []string
+		for category := range m.functions[name].errors {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+
+		for _, category := range categories {
+			fmt.Fprintf(&out, "tfpolicy_plugin_function_errors_total{function=%s,category=%s} %d\n", labelValue(name), labelValue(category), m.functions[name].errors[category])
+		}
+	}
+
+	writeHeader(&out, "tfpolicy_plugin_function_duration_seconds", "histogram", "Duration of function executions in seconds.")
+	for _, name := range names {
+		m.functions[name].duration.write(&out, "tfpolicy_plugin_function_duration_seconds", name)
+	}
+
+	writeHeader(&out, "tfpolicy_plugin_function_argument_bytes", "histogram", "Total size of the msgpack encoded arguments of function executions in bytes.")
+	for _, name := range names {
+		m.functions[name].argumentBytes.write(&out, "tfpolicy_plugin_function_argument_bytes", name)
+	}
+
+	n, err := io.WriteString(w, out.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(value float64) {
+	for ix, bucket := range h.buckets {
+		if value <= bucket {
+			h.counts[ix]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *histogram) write(out *strings.Builder, metric string, function string) {
+	for ix, bucket := range h.buckets {
+		fmt.Fprintf(out, "%s_bucket{function=%s,le=\"%s\"} %d\n", metric, labelValue(function), formatFloat(bucket), h.counts[ix])
+	}
+	fmt.Fprintf(out, "%s_bucket{function=%s,le=\"+Inf\"} %d\n", metric, labelValue(function), h.count)
+	fmt.Fprintf(out, "%s_sum{function=%s} %s\n", metric, labelValue(function), formatFloat(h.sum))
+	fmt.Fprintf(out, "%s_count{function=%s} %d\n", metric, labelValue(function), h.count)
+}
+
+func writeHeader(out *strings.Builder, metric, kind, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n", metric, help)
+	fmt.Fprintf(out, "# TYPE %s %s\n", metric, kind)
+}
+
+func labelValue(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, "\"", "\\\"")
+	value = strings.ReplaceAll(value, "\n", "\\n")
+	return "\"" + value + "\""
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestGetMetrics(t *testing.T) {
+	RegisterFunction("measured", func(fail bool) (bool, error) {
+		if fail {
+			return false, errors.New("failed")
+		}
+		return true, nil
+	}, WithAliases("measured_alias"))
+
+	server := new(GrpcServer)
+	for ix, value := range []bool{true, false, false} {
+		argument, err := msgpack.Marshal(cty.BoolVal(value), cty.Bool)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		name := "measured"
+		if ix == 2 {
+			name = "measured_alias"
+		}
+		server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+			Name:      name,
+			Arguments: [][]byte{argument},
+		})
+	}
+	for _, name := range []string{"missing", "also_missing"} {
+		server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+			Name: name,
+		})
+	}
+
+	response, err := server.GetMetrics(context.Background(), new(proto.GetMetricsRequest))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	metrics := string(response.Metrics)
+
+	for _, want := range []string{
+		"# TYPE tfpolicy_plugin_function_calls_total counter\n",
+		"tfpolicy_plugin_function_calls_total{function=\"measured\"} 3\n",
+		"tfpolicy_plugin_function_calls_total{function=\"<unknown>\"} 2\n",
+		"tfpolicy_plugin_function_errors_total{function=\"measured\",category=\"function\"} 1\n",
+		"tfpolicy_plugin_function_errors_total{function=\"<unknown>\",category=\"not_found\"} 2\n",
+		"# TYPE tfpolicy_plugin_function_duration_seconds histogram\n",
+		"tfpolicy_plugin_function_duration_seconds_bucket{function=\"measured\",le=\"+Inf\"} 3\n",
+		"tfpolicy_plugin_function_duration_seconds_count{function=\"measured\"} 3\n",
+		"tfpolicy_plugin_function_argument_bytes_bucket{function=\"measured\",le=\"64\"} 3\n",
+		"tfpolicy_plugin_function_argument_bytes_sum{function=\"measured\"} 3\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, metrics)
+		}
+	}
+	for _, unwanted := range []string{"measured_alias", "also_missing"} {
+		if strings.Contains(metrics, unwanted) {
+			t.Errorf("expected no series for %s, got:\n%s", unwanted, metrics)
+		}
+	}
+}
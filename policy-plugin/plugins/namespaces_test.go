@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestRegisterFunction_Namespaces(t *testing.T) {
+	RegisterFunction("example::arn_parse", func(arn string) (string, error) {
+		return arn, nil
+	}, WithAliases("arn_parse", "legacy::parse_arn"))
+
+	server := new(GrpcServer)
+	list, err := server.ListFunctions(context.Background(), new(proto.ListFunctionsRequest))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fn, ok := list.Functions["example::arn_parse"]
+	if !ok {
+		t.Fatalf("expected example::arn_parse to be listed")
+	}
+	if fn.Namespace != "example" {
+		t.Errorf("expected namespace example, got %q", fn.Namespace)
+	}
+	if diff := cmp.Diff([]string{"arn_parse", "legacy::parse_arn"}, fn.Aliases); diff != "" {
+		t.Errorf("unexpected aliases (-want +got):\n%s", diff)
+	}
+	if _, ok := list.Functions["arn_parse"]; ok {
+		t.Errorf("expected aliases to be reported on the function, not as duplicates")
+	}
+
+	argument, err := msgpack.Marshal(cty.StringVal("arn:aws:s3:::bucket"), cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"example::arn_parse", "arn_parse", "legacy::parse_arn"} {
+		if _, err := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+			Name:      name,
+			Arguments: [][]byte{argument},
+		}); err != nil {
+			t.Errorf("unexpected error calling %s: %s", name, err)
+		}
+	}
+
+	result, err := CallFunction("legacy::parse_arn", cty.StringVal("arn"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.RawEquals(cty.StringVal("arn")) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+}
+
+func TestRegisterFunction_InvalidNames(t *testing.T) {
+	RegisterFunction("taken", func() (string, error) {
+		return "", nil
+	}, WithAliases("taken_alias"))
+
+	tcs := map[string]struct {
+		name    string
+		aliases []string
+	}{
+		"empty namespace":    {name: "::name"},
+		"empty name":         {name: "namespace::"},
+		"duplicate":          {name: "taken"},
+		"duplicate of alias": {name: "taken_alias"},
+		"alias of function":  {name: "fresh", aliases: []string{"taken"}},
+		"alias of alias":     {name: "fresh", aliases: []string{"taken_alias"}},
+		"alias of itself":    {name: "fresh", aliases: []string{"fresh"}},
+		"duplicate aliases":  {name: "fresh", aliases: []string{"old", "old"}},
+		"invalid alias":      {name: "fresh", aliases: []string{"a::::b"}},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registration to panic")
+				}
+			}()
+			RegisterFunction(tc.name, func() (string, error) {
+				return "", nil
+			}, WithAliases(tc.aliases...))
+		})
+	}
+
+	if _, ok := functions["fresh"]; ok {
+		t.Errorf("expected failed registrations to leave the registry unchanged")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServeOption configures optional behaviour of Serve.
+type ServeOption func(*serveConfig)
+
+type serveConfig struct {
+	name    string
+	version string
+
+	logger         hclog.Logger
+	metrics        *metrics
+	metricsAddress string
+
+	lifecycle *lifecycle
+	health    *healthServer
+
+	tracerProvider trace.TracerProvider
+	traceFile      string
+	traceEndpoint  string
+}
+
+func newServeConfig(opts []ServeOption) *serveConfig {
+	config := &serveConfig{
+		logger:    hclog.NewNullLogger(),
+		metrics:   newMetrics(),
+		lifecycle: new(lifecycle),
+	}
+	config.health = newHealthServer(config.lifecycle)
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// pluginServeConfig builds the go-plugin configuration for serving the
+// registered functions.
+func (config *serveConfig) pluginServeConfig() *plugin.ServeConfig {
+	return &plugin.ServeConfig{
+		HandshakeConfig:  Handshake,
+		VersionedPlugins: versionedPlugins(config),
+		GRPCServer:       config.health.grpcServer,
+	}
+}
+
+// WithLogger sets the logger the framework uses to report on function
+// execution, such as cache statistics. Plugins will usually pass the result of
+// NewLogger here. By default, nothing is logged.
+func WithLogger(logger hclog.Logger) ServeOption {
+	return func(config *serveConfig) {
+		config.logger = logger
+	}
+}
+
+// WithMetricsAddress serves the function execution metrics in the Prometheus
+// text format at /metrics on the given local address, such as
+// "127.0.0.1:9100". The metrics are always available through the GetMetrics
+// RPC.
+func WithMetricsAddress(address string) ServeOption {
+	return func(config *serveConfig) {
+		config.metricsAddress = address
+	}
+}
+
+// serveMetrics starts the metrics HTTP server if an address was configured.
+func (config *serveConfig) serveMetrics() error {
+	if len(config.metricsAddress) == 0 {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", config.metricsAddress)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", config.metrics)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			config.logger.Error("metrics server stopped", "error", err)
+		}
+	}()
+	return nil
+}
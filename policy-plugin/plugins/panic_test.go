@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestExecuteFunction_RecoversPanics(t *testing.T) {
+	RegisterFunction("panics", func(fail bool) (bool, error) {
+		if fail {
+			panic("something went wrong")
+		}
+		return true, nil
+	})
+
+	server := new(GrpcServer)
+	server.init()
+	records, unsubscribe := server.logs.subscribe(hclog.Debug)
+	defer unsubscribe()
+
+	execute := func(fail bool) *proto.ExecuteFunctionResponse {
+		argument, err := msgpack.Marshal(cty.BoolVal(fail), cty.Bool)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+			Name:      "panics",
+			Arguments: [][]byte{argument},
+			CallId:    "call-1",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return response
+	}
+
+	response := execute(true)
+	if len(response.Diagnostics) != 1 {
+		t.Fatalf("expected exactly one diagnostic, got %d", len(response.Diagnostics))
+	}
+	diagnostic := response.Diagnostics[0]
+	if diagnostic.Severity != proto.Diagnostic_ERROR || !strings.Contains(diagnostic.Detail, `"panics"`) || !strings.Contains(diagnostic.Detail, "something went wrong") {
+		t.Errorf("unexpected diagnostic: %v", diagnostic)
+	}
+	if strings.Contains(diagnostic.Detail, "goroutine") {
+		t.Errorf("expected the stack trace to be left out of the diagnostic: %s", diagnostic.Detail)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expected exactly one record, got %d", len(records))
+	}
+	record := <-records
+	if record.Level != "debug" || record.CallId != "call-1" || !strings.Contains(string(record.Fields), "goroutine") {
+		t.Errorf("expected the stack trace to be logged, got %v", record)
+	}
+
+	// The server should keep working after a panic.
+	response = execute(false)
+	if len(response.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", response.Diagnostics)
+	}
+
+	metrics, err := server.GetMetrics(context.Background(), new(proto.GetMetricsRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `tfpolicy_plugin_function_errors_total{function="panics",category="panic"} 1`; !strings.Contains(string(metrics.Metrics), want) {
+		t.Errorf("expected metrics to contain %q", want)
+	}
+}
@@ -9,13 +9,16 @@ import (
 
 	"github.com/hashicorp/go-plugin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
 )
 
 var (
+	// Handshake is shared by plugins and hosts. The protocol version here is
+	// only used by hosts that don't negotiate a version, see ClientPlugins.
 	Handshake = plugin.HandshakeConfig{
-		ProtocolVersion:  1,
+		ProtocolVersion:  ProtocolVersion1,
 		MagicCookieKey:   "TF_POLICY_PLUGIN",
 		MagicCookieValue: "95ADAEF3D8C4",
 	}
@@ -26,13 +29,58 @@ var (
 
 type PluginServer struct {
 	plugin.NetRPCUnsupportedPlugin
+
+	config *serveConfig
+
+	// protocolVersion is the version of the protocol to serve, defaulting to
+	// LatestProtocolVersion.
+	protocolVersion int
 }
 
-func (p *PluginServer) GRPCServer(_ *plugin.GRPCBroker, server *grpc.Server) error {
-	proto.RegisterPluginServer(server, new(GrpcServer))
+func (p *PluginServer) GRPCServer(broker *plugin.GRPCBroker, server *grpc.Server) error {
+	config := p.config
+	if config == nil {
+		config = newServeConfig(nil)
+	}
+
+	latest := &GrpcServer{
+		name:      config.name,
+		version:   config.version,
+		logger:    config.logger,
+		metrics:   config.metrics,
+		tracer:    config.tracer(),
+		lifecycle: config.lifecycle,
+		broker:    broker,
+	}
+
+	// go-plugin registers a health service of its own, which the server
+	// created by Serve routes to the plugin's health service instead.
+	if _, ok := server.GetServiceInfo()[grpc_health_v1.Health_ServiceDesc.ServiceName]; !ok {
+		grpc_health_v1.RegisterHealthServer(server, config.health)
+	}
+
+	switch p.protocolVersion {
+	case ProtocolVersion1:
+		proto.RegisterPluginServer(server, &grpcServerV1{latest})
+	case 0, LatestProtocolVersion:
+		proto.RegisterPluginServer(server, latest)
+	default:
+		return fmt.Errorf("unsupported protocol version %d", p.protocolVersion)
+	}
 	return nil
 }
 
-func (p *PluginServer) GRPCClient(_ context.Context, _ *plugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
+func (p *PluginServer) GRPCClient(_ context.Context, broker *plugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
+	return &PluginClient{
+		PluginClient: proto.NewPluginClient(conn),
+		Broker:       broker,
+	}, nil
+}
+
+// PluginClient is the client dispensed to hosts. The broker lets the host serve
+// the Host service to the plugin, see PluginSetupRequest.HostBrokerId.
+type PluginClient struct {
+	proto.PluginClient
+
+	Broker *plugin.GRPCBroker
 }
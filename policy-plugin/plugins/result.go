@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins/convert"
+)
+
+var (
+	_ error = (*ResultError)(nil)
+)
+
+// ResultError is returned when a function returns a value that doesn't conform
+// to its return type.
+type ResultError struct {
+	// Function is the name of the function.
+	Function string
+
+	// Path is the path inside the result that didn't conform, which is empty
+	// if the result itself didn't.
+	Path convert.Path
+
+	Err error
+}
+
+func (e *ResultError) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("function %s returned an invalid result: %v", e.Function, e.Err)
+	}
+	return fmt.Sprintf("function %s returned an invalid result at %s: %v", e.Function, e.Path, e.Err)
+}
+
+func (e *ResultError) Unwrap() error {
+	return e.Err
+}
+
+// conformResult returns result as a value of returnType. Results that don't
+// conform are converted if cty can do so safely, and rejected with a
+// ResultError otherwise.
+func conformResult(name string, result cty.Value, returnType cty.Type) (cty.Value, error) {
+	if errs := result.Type().TestConformance(returnType); len(errs) == 0 {
+		return result, nil
+	}
+
+	if conversion := ctyconvert.GetConversion(result.Type(), returnType); conversion != nil {
+		converted, err := conversion(result)
+		if err != nil {
+			return cty.NilVal, resultError(name, err)
+		}
+		return converted, nil
+	}
+
+	return cty.NilVal, resultError(name, nonconforming(nil, result, returnType))
+}
+
+// conforming returns a copy of the named function fn that checks its results
+// with conformResult. go-cty panics within Call when a function returns a value
+// that doesn't conform to its return type, so such results can't be converted
+// and are reported with conformanceError instead.
+func conforming(name string, fn function.Function) function.Function {
+	return function.New(&function.Spec{
+		Description: fn.Description(),
+		Params:      fn.Params(),
+		VarParam:    fn.VarParam(),
+		Type:        fn.ReturnTypeForValues,
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			result, err := fn.Call(args)
+			if err != nil {
+				return cty.NilVal, conformanceError(name, err)
+			}
+			return conformResult(name, result, retType)
+		},
+	})
+}
+
+// conformanceError returns a ResultError for the named function if err is the
+// panic go-cty raises for results that don't conform to the return type, and
+// err otherwise.
+func conformanceError(name string, err error) error {
+	var panicErr function.PanicError
+	if !errors.As(err, &panicErr) {
+		return err
+	}
+	if cause, ok := panicErr.Value.(error); ok && strings.Contains(cause.Error(), "does not conform to expected return type") {
+		return &ResultError{Function: name, Err: cause}
+	}
+	return err
+}
+
+// nonconforming returns an error at the deepest path within value that doesn't
+// conform to ty, so the error points at the element of a collection that
+// didn't conform rather than at the whole collection.
+func nonconforming(path cty.Path, value cty.Value, ty cty.Type) error {
+	if len(value.Type().TestConformance(ty)) == 0 {
+		return nil
+	}
+	mismatch := path.NewErrorf("%s required, but received %s", ty.FriendlyName(), value.Type().FriendlyName())
+	if value.IsNull() || !value.IsKnown() {
+		return mismatch
+	}
+
+	valueType := value.Type()
+	switch {
+	case ty.IsObjectType() && (valueType.IsObjectType() || valueType.IsMapType()):
+		names := make([]string, 0, len(ty.AttributeTypes()))
+		for name := range ty.AttributeTypes() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			attribute, err := applyAttribute(value, name)
+			if err != nil {
+				return path.GetAttr(name).NewErrorf("attribute required")
+			}
+			if err := nonconforming(path.GetAttr(name), attribute, ty.AttributeType(name)); err != nil {
+				return err
+			}
+		}
+		for it := value.ElementIterator(); it.Next(); {
+			key, _ := it.Element()
+			if !ty.HasAttribute(key.AsString()) {
+				return path.GetAttr(key.AsString()).NewErrorf("unexpected attribute")
+			}
+		}
+	case ty.IsTupleType() && valueType.IsTupleType():
+		elements := ty.TupleElementTypes()
+		if len(elements) != value.LengthInt() {
+			return mismatch
+		}
+		for ix, element := range value.AsValueSlice() {
+			if err := nonconforming(path.IndexInt(ix), element, elements[ix]); err != nil {
+				return err
+			}
+		}
+	case ty.IsCollectionType() && value.CanIterateElements():
+		for it := value.ElementIterator(); it.Next(); {
+			key, element := it.Element()
+			if err := nonconforming(path.Index(key), element, ty.ElementType()); err != nil {
+				return err
+			}
+		}
+	}
+	return mismatch
+}
+
+// applyAttribute returns the named attribute of an object or element of a map.
+func applyAttribute(value cty.Value, name string) (cty.Value, error) {
+	if value.Type().IsObjectType() {
+		if !value.Type().HasAttribute(name) {
+			return cty.NilVal, fmt.Errorf("missing attribute %q", name)
+		}
+		return value.GetAttr(name), nil
+	}
+	key := cty.StringVal(name)
+	if !value.HasIndex(key).True() {
+		return cty.NilVal, fmt.Errorf("missing key %q", name)
+	}
+	return value.Index(key), nil
+}
+
+// resultError wraps err as a ResultError for the named function, taking the
+// path from err if it has one.
+func resultError(name string, err error) *ResultError {
+	var ctyPathErr cty.PathError
+	if errors.As(err, &ctyPathErr) {
+		if path, pathErr := convert.FromCtyPath(ctyPathErr.Path); pathErr == nil {
+			return &ResultError{Function: name, Path: path, Err: ctyPathErr}
+		}
+	}
+
+	var pathErr *convert.PathError
+	if errors.As(err, &pathErr) {
+		return &ResultError{Function: name, Path: pathErr.Path, Err: pathErr.Err}
+	}
+	return &ResultError{Function: name, Err: err}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestConformResult(t *testing.T) {
+	itemsType := cty.Object(map[string]cty.Type{
+		"items": cty.List(cty.Object(map[string]cty.Type{
+			"name": cty.String,
+		})),
+	})
+
+	tcs := map[string]struct {
+		result     cty.Value
+		returnType cty.Type
+		expected   cty.Value
+		path       string
+	}{
+		"conforming": {
+			result:     cty.StringVal("value"),
+			returnType: cty.String,
+			expected:   cty.StringVal("value"),
+		},
+		"dynamic": {
+			result:     cty.NumberIntVal(1),
+			returnType: cty.DynamicPseudoType,
+			expected:   cty.NumberIntVal(1),
+		},
+		"safe conversion": {
+			result:     cty.TupleVal([]cty.Value{cty.NumberIntVal(1), cty.True}),
+			returnType: cty.List(cty.String),
+			expected:   cty.ListVal([]cty.Value{cty.StringVal("1"), cty.StringVal("true")}),
+		},
+		"unsafe conversion": {
+			result:     cty.StringVal("1"),
+			returnType: cty.Number,
+		},
+		"nested": {
+			result: cty.ObjectVal(map[string]cty.Value{
+				"items": cty.TupleVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("a")}),
+					cty.ObjectVal(map[string]cty.Value{"name": cty.ListValEmpty(cty.String)}),
+				}),
+			}),
+			returnType: itemsType,
+			path:       "items[1].name",
+		},
+		"missing attribute": {
+			result:     cty.EmptyObjectVal,
+			returnType: cty.Object(map[string]cty.Type{"name": cty.String}),
+			path:       "name",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			actual, err := conformResult("fn", tc.result, tc.returnType)
+			if tc.expected == cty.NilVal {
+				var resultErr *ResultError
+				if !errors.As(err, &resultErr) {
+					t.Fatalf("expected a result error, got %v", err)
+				}
+				if resultErr.Function != "fn" || resultErr.Path.String() != tc.path {
+					t.Errorf("expected an error for fn at %q, got %s", tc.path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !actual.RawEquals(tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExecuteFunction_InvalidResult(t *testing.T) {
+	ownerType := cty.Object(map[string]cty.Type{"name": cty.String})
+	RegisterFunction("invalid_owner", func(owner string) (cty.Value, error) {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name": cty.ListVal([]cty.Value{cty.StringVal(owner)}),
+		}), nil
+	}, WithReturnType(func([]cty.Type) (cty.Type, error) {
+		return ownerType, nil
+	}))
+	RegisterFunction("converted_owner", func(owner string) (cty.Value, error) {
+		return cty.ObjectVal(map[string]cty.Value{"name": cty.NumberIntVal(int64(len(owner)))}), nil
+	}, WithReturnType(func([]cty.Type) (cty.Type, error) {
+		return ownerType, nil
+	}))
+
+	ctx := context.Background()
+	server := new(GrpcServer)
+	argument, err := msgpack.Marshal(cty.StringVal("team"), cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+		Name:      "invalid_owner",
+		Arguments: [][]byte{argument},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(response.Diagnostics) != 1 || response.Diagnostics[0].Summary != "Invalid function result" {
+		t.Fatalf("expected an invalid result diagnostic, got %v", response.Diagnostics)
+	}
+	if detail := response.Diagnostics[0].Detail; !strings.Contains(detail, "invalid_owner") || !strings.Contains(detail, "at name") {
+		t.Errorf("expected the diagnostic to name the function and the path, got %q", detail)
+	}
+
+	response, err = server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+		Name:      "converted_owner",
+		Arguments: [][]byte{argument},
+	})
+	if err != nil || len(response.Diagnostics) > 0 {
+		t.Fatalf("unexpected error: %v %v", err, response.GetDiagnostics())
+	}
+	result, err := msgpack.Unmarshal(response.Result, ownerType)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("4")}); !result.RawEquals(expected) {
+		t.Errorf("expected %#v, got %#v", expected, result)
+	}
+}
+
+func TestExecuteFunction_InvalidResultStatic(t *testing.T) {
+	ownerType := cty.Object(map[string]cty.Type{"name": cty.String})
+	RegisterFunctionDirect("direct_number", function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func([]cty.Value, cty.Type) (cty.Value, error) {
+			return cty.NumberIntVal(1), nil
+		},
+	}))
+	RegisterFunctionDirect("direct_owners", function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.List(ownerType)),
+		Impl: func([]cty.Value, cty.Type) (cty.Value, error) {
+			return cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("a")}),
+				cty.ObjectVal(map[string]cty.Value{"name": cty.ListValEmpty(cty.String)}),
+			}), nil
+		},
+	}))
+
+	ctx := context.Background()
+	server := new(GrpcServer)
+
+	// go-cty rejects the results of functions with a static return type
+	// before they can be converted, so neither result is converted.
+	for _, name := range []string{"direct_number", "direct_owners"} {
+		response, err := server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{Name: name})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(response.Diagnostics) != 1 || response.Diagnostics[0].Summary != "Invalid function result" {
+			t.Fatalf("expected an invalid result diagnostic for %s, got %v", name, response.Diagnostics)
+		}
+		if detail := response.Diagnostics[0].Detail; !strings.Contains(detail, name) || !strings.Contains(detail, "does not conform") {
+			t.Errorf("expected the diagnostic to name the function %s, got %q", name, detail)
+		}
+	}
+
+	var resultErr *ResultError
+	if _, err := CallFunction("direct_owners"); !errors.As(err, &resultErr) || resultErr.Function != "direct_owners" {
+		t.Errorf("expected a result error calling the function directly, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func mergeObjectTypes(args []cty.Type) (cty.Type, error) {
+	attributes := make(map[string]cty.Type)
+	for _, arg := range args {
+		if !arg.IsObjectType() {
+			return cty.NilType, errors.New("arguments must be objects")
+		}
+		for name, attribute := range arg.AttributeTypes() {
+			attributes[name] = attribute
+		}
+	}
+	return cty.Object(attributes), nil
+}
+
+func TestWithReturnType(t *testing.T) {
+	RegisterFunction("merge_objects", func(a, b cty.Value) (cty.Value, error) {
+		attributes := a.AsValueMap()
+		if attributes == nil {
+			attributes = make(map[string]cty.Value)
+		}
+		for name, value := range b.AsValueMap() {
+			attributes[name] = value
+		}
+		return cty.ObjectVal(attributes), nil
+	}, WithReturnType(mergeObjectTypes))
+
+	ctx := context.Background()
+	server := new(GrpcServer)
+
+	list, err := server.ListFunctions(ctx, new(proto.ListFunctionsRequest))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fn := list.Functions["merge_objects"]
+	if !fn.DynamicReturnType || string(fn.ReturnType) != `"dynamic"` {
+		t.Errorf("expected a dynamic return type, got %s (%t)", fn.ReturnType, fn.DynamicReturnType)
+	}
+
+	a := cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("bucket")})
+	b := cty.ObjectVal(map[string]cty.Value{"count": cty.NumberIntVal(2)})
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.StringVal("bucket"),
+		"count": cty.NumberIntVal(2),
+	})
+
+	var types [][]byte
+	for _, arg := range []cty.Value{a, b} {
+		encoded, err := ctyjson.MarshalType(arg.Type())
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, encoded)
+	}
+	returnType, err := server.GetReturnType(ctx, &proto.GetReturnTypeRequest{
+		Name:          "merge_objects",
+		ArgumentTypes: types,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual, err := ctyjson.UnmarshalType(returnType.ReturnType); err != nil || !actual.Equals(expected.Type()) {
+		t.Errorf("expected return type %#v, got %s (%v)", expected.Type(), returnType.ReturnType, err)
+	}
+
+	invalid, err := server.GetReturnType(ctx, &proto.GetReturnTypeRequest{
+		Name:          "merge_objects",
+		ArgumentTypes: [][]byte{[]byte(`"string"`), []byte(`"string"`)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(invalid.Diagnostics) != 1 || invalid.Diagnostics[0].Summary != "Invalid function arguments" {
+		t.Errorf("expected an invalid arguments diagnostic, got %v", invalid.Diagnostics)
+	}
+
+	var arguments [][]byte
+	for _, arg := range []cty.Value{a, b} {
+		encoded, err := msgpack.Marshal(arg, cty.DynamicPseudoType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arguments = append(arguments, encoded)
+	}
+	response, err := server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+		Name:      "merge_objects",
+		Arguments: arguments,
+	})
+	if err != nil || len(response.Diagnostics) > 0 {
+		t.Fatalf("unexpected error: %v %v", err, response.GetDiagnostics())
+	}
+	result, err := msgpack.Unmarshal(response.Result, expected.Type())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.RawEquals(expected) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+
+	result, err = CallFunction("merge_objects", a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.RawEquals(expected) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+}
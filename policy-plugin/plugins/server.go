@@ -4,38 +4,147 @@
 package plugins
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 	"github.com/zclconf/go-cty/cty"
+	ctyfunction "github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 	"github.com/zclconf/go-cty/cty/msgpack"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
 )
 
-func Serve() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: Handshake,
-		Plugins: map[string]plugin.Plugin{
-			"plugin": new(PluginServer),
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
+func Serve(opts ...ServeOption) {
+	if err := serve(newServeConfig(opts)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// serve implements Serve, returning an error instead of exiting so that the
+// deferred cleanup, such as flushing traces, runs before the plugin exits.
+func serve(config *serveConfig) error {
+	if format := os.Getenv(DocsEnvVar); len(format) > 0 {
+		if err := Docs(os.Stdout, format); err != nil {
+			return fmt.Errorf("failed to generate docs: %w", err)
+		}
+		return nil
+	}
+
+	stopTracing, err := config.startTracing(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to start tracing: %w", err)
+	}
+	defer stopTracing()
+
+	if err := config.serveMetrics(); err != nil {
+		return fmt.Errorf("failed to serve metrics: %w", err)
+	}
+
+	if debug, _ := strconv.ParseBool(os.Getenv(DebugEnvVar)); debug {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if err := serveDebug(ctx, config, os.Stdout); err != nil {
+			return fmt.Errorf("failed to serve in debug mode: %w", err)
+		}
+		return nil
+	}
+
+	plugin.Serve(config.pluginServeConfig())
+	config.shutdownAfterServe()
+	return nil
+}
+
+type GrpcServer struct {
+	name    string
+	version string
+
+	logger  hclog.Logger
+	metrics *metrics
+	tracer  trace.Tracer
+
+	lifecycle *lifecycle
+
+	// broker connects to the Host service the client serves, if any.
+	broker    *plugin.GRPCBroker
+	hostMutex sync.Mutex
+	host      Host
+
+	once sync.Once
+	logs *logHub
+}
+
+// init fills in the defaults for any fields the server was created without.
+func (g *GrpcServer) init() {
+	g.once.Do(func() {
+		if g.logger == nil {
+			g.logger = hclog.NewNullLogger()
+		}
+		if g.metrics == nil {
+			g.metrics = newMetrics()
+		}
+		if g.lifecycle == nil {
+			g.lifecycle = new(lifecycle)
+		}
+		if g.tracer == nil {
+			g.tracer = noop.NewTracerProvider().Tracer(tracerName)
+		}
+		g.logs = newLogHub(g.logger)
 	})
 }
 
-type GrpcServer struct{}
+func (g *GrpcServer) Setup(ctx context.Context, request *proto.PluginSetupRequest) (*proto.PluginSetupResponse, error) {
+	g.init()
+
+	if request.HostBrokerId != 0 {
+		if g.broker == nil {
+			return nil, errors.New("the plugin can't connect to the host without a broker")
+		}
+
+		conn, err := g.broker.Dial(request.HostBrokerId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the host: %w", err)
+		}
+
+		g.hostMutex.Lock()
+		g.host = newGrpcHost(proto.NewHostClient(conn), request.ClientCapabilities)
+		g.hostMutex.Unlock()
+	}
 
-func (g *GrpcServer) Setup(context.Context, *proto.PluginSetupRequest) (*proto.PluginSetupResponse, error) {
-	// Nothing to do at the moment.
+	if err := g.lifecycle.setup(g.withHost(ctx)); err != nil {
+		return nil, err
+	}
 	return new(proto.PluginSetupResponse), nil
 }
 
+func (g *GrpcServer) Stop(ctx context.Context, _ *proto.StopRequest) (*proto.StopResponse, error) {
+	g.init()
+
+	return &proto.StopResponse{
+		Diagnostics: g.lifecycle.stop(ctx),
+	}, nil
+}
+
 func (g *GrpcServer) ListFunctions(context.Context, *proto.ListFunctionsRequest) (*proto.ListFunctionsResponse, error) {
 	fns := make(map[string]*proto.Function, len(functions))
 	for name, function := range functions {
-		fn, err := proto.FromCtyFunction(function)
+		fn, err := function.describe()
 		if err != nil {
 			return nil, err
 		}
@@ -47,17 +156,207 @@ func (g *GrpcServer) ListFunctions(context.Context, *proto.ListFunctionsRequest)
 	}, nil
 }
 
-func (g *GrpcServer) ExecuteFunction(_ context.Context, request *proto.ExecuteFunctionRequest) (*proto.ExecuteFunctionResponse, error) {
-	function, ok := functions[request.Name]
+func (g *GrpcServer) GetReturnType(_ context.Context, request *proto.GetReturnTypeRequest) (*proto.GetReturnTypeResponse, error) {
+	function, ok := lookupFunction(request.Name)
+	if !ok {
+		return &proto.GetReturnTypeResponse{
+			Diagnostics: []*proto.Diagnostic{
+				{
+					Severity: proto.Diagnostic_ERROR,
+					Summary:  errorSummaries[errorNotFound],
+					Detail:   fmt.Sprintf("function %q not found", request.Name),
+				},
+			},
+		}, nil
+	}
+
+	types := make([]cty.Type, len(request.ArgumentTypes))
+	for ix, argumentType := range request.ArgumentTypes {
+		var err error
+		if types[ix], err = ctyjson.UnmarshalType(argumentType); err != nil {
+			return nil, fmt.Errorf("invalid type for argument %d: %w", ix, err)
+		}
+	}
+
+	returnType, err := function.ReturnType(types)
+	if err != nil {
+		return &proto.GetReturnTypeResponse{
+			Diagnostics: []*proto.Diagnostic{
+				{
+					Severity: proto.Diagnostic_ERROR,
+					Summary:  errorSummaries[errorInvalidArguments],
+					Detail:   err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	encoded, err := ctyjson.MarshalType(returnType)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.GetReturnTypeResponse{
+		ReturnType: encoded,
+	}, nil
+}
+
+func (g *GrpcServer) ExecuteFunction(ctx context.Context, request *proto.ExecuteFunctionRequest) (*proto.ExecuteFunctionResponse, error) {
+	g.init()
+
+	var argumentBytes int
+	for _, argument := range request.Arguments {
+		argumentBytes += len(argument)
+	}
+
+	ctx, span := g.tracer.Start(extractTraceContext(ctx), "ExecuteFunction "+request.Name, trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("function.name", request.Name),
+		attribute.Int("function.argument_bytes", argumentBytes))
+
+	callID := request.CallId
+	if len(callID) == 0 {
+		callID = newCallID()
+	}
+
+	// Calls are measured under the canonical name of the function, so calls
+	// through aliases count towards the function they are aliases of.
+	measured := unknownFunction
+	if function, ok := lookupFunction(request.Name); ok {
+		measured = function.name
+	}
+
+	start := time.Now()
+	response, category, err := g.executeFunction(ctx, request, callID)
+	g.metrics.observe(measured, time.Since(start), argumentBytes, category)
+
+	if err != nil {
+		span.SetAttributes(attribute.String("function.error_category", category))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		// Failures are reported as diagnostics, so the host can tell them
+		// apart from problems with the connection to the plugin.
+		return &proto.ExecuteFunctionResponse{
+			CallId: callID,
+			Diagnostics: []*proto.Diagnostic{
+				{
+					Severity: proto.Diagnostic_ERROR,
+					Summary:  errorSummaries[category],
+					Detail:   err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	if len(category) > 0 {
+		span.SetAttributes(attribute.String("function.error_category", category))
+		span.SetStatus(codes.Error, category)
+	}
+	return response, nil
+}
+
+// executeFunction executes the requested function, and returns the category of
+// any error for the metrics.
+func (g *GrpcServer) executeFunction(ctx context.Context, request *proto.ExecuteFunctionRequest, callID string) (response *proto.ExecuteFunctionResponse, category string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			response, category, err = g.panicked(request.Name, callID, r, debug.Stack()), errorPanic, nil
+		}
+	}()
+
+	if !g.lifecycle.begin() {
+		return nil, errorUnavailable, errors.New("the plugin is shutting down")
+	}
+	defer g.lifecycle.end()
+
+	function, ok := lookupFunction(request.Name)
 	if !ok {
-		return nil, fmt.Errorf("function %q not found", request.Name)
+		return nil, errorNotFound, fmt.Errorf("function %q not found", request.Name)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("function.call_id", callID))
+
+	var key string
+	if function.cache != nil {
+		key = cacheKey(function.name, request.Arguments)
+		result, ok := function.cache.get(key)
+		g.logCache(function.name, ok, function.cache)
+		span.SetAttributes(attribute.Bool("function.cache_hit", ok))
+		if ok {
+			return &proto.ExecuteFunctionResponse{
+				Result:      result,
+				CallId:      callID,
+				Diagnostics: function.warnings(),
+			}, "", nil
+		}
+	}
+
+	_, end := startSpan(ctx, g.tracer, "convert arguments")
+	args, err := unmarshalArguments(function, request.Arguments)
+	end(err)
+	if err != nil {
+		return nil, errorInvalidArguments, err
+	}
+
+	ctx = ContextWithLogger(ctx, g.logs.callLogger(function.name, callID))
+	ctx = ContextWithCall(g.withHost(ctx), Call{Function: function.name, ID: callID})
+	callCtx, end := startSpan(ctx, g.tracer, "call function")
+	ret, err := function.call(callCtx, args)
+	end(err)
+	var panicErr ctyfunction.PanicError
+	if errors.As(err, &panicErr) {
+		return g.panicked(function.name, callID, panicErr.Value, panicErr.Stack), errorPanic, nil
+	}
+	var resultErr *ResultError
+	if errors.As(err, &resultErr) {
+		return nil, errorResult, err
+	}
+	if err != nil {
+		return nil, errorFunction, err
+	}
+
+	_, end = startSpan(ctx, g.tracer, "convert result")
+	result, err := marshalResult(function, args, ret)
+	end(err)
+	if err != nil {
+		return nil, errorResult, err
+	}
+
+	if function.cache != nil {
+		function.cache.put(key, result)
+	}
+
+	return &proto.ExecuteFunctionResponse{
+		Result:      result,
+		CallId:      callID,
+		Diagnostics: function.warnings(),
+	}, "", nil
+}
+
+// panicked logs the stack of a panic within the named function, and converts
+// the panic into an internal error diagnostic so the plugin keeps serving.
+func (g *GrpcServer) panicked(name, callID string, value interface{}, stack []byte) *proto.ExecuteFunctionResponse {
+	g.logs.callLogger(name, callID).Debug("function panicked", "panic", fmt.Sprint(value), "stack", string(stack))
+	return &proto.ExecuteFunctionResponse{
+		CallId: callID,
+		Diagnostics: []*proto.Diagnostic{
+			{
+				Severity: proto.Diagnostic_ERROR,
+				Summary:  "Internal error in plugin function",
+				Detail:   fmt.Sprintf("The function %q panicked: %v. This is a bug in the plugin, run the plugin with debug logging to see the stack trace.", name, value),
+			},
+		},
 	}
+}
 
+func unmarshalArguments(function *registeredFunction, arguments [][]byte) ([]cty.Value, error) {
 	parameters := function.Params()
 	variadicParameter := function.VarParam()
 
-	args := make([]cty.Value, len(request.Arguments))
-	for i, argument := range request.Arguments {
+	args := make([]cty.Value, len(arguments))
+	for i, argument := range arguments {
 		if i >= len(parameters) {
 			if variadicParameter == nil {
 				return nil, errors.New("too many arguments")
@@ -78,23 +377,86 @@ func (g *GrpcServer) ExecuteFunction(_ context.Context, request *proto.ExecuteFu
 		}
 		args[i] = arg
 	}
+	return args, nil
+}
 
-	ret, err := function.Call(args)
+// marshalResult encodes the result of a call, after making sure it conforms to
+// the return type of the function for its arguments.
+func marshalResult(function *registeredFunction, args []cty.Value, ret cty.Value) ([]byte, error) {
+	returnType, err := function.ReturnTypeForValues(args)
 	if err != nil {
 		return nil, err
 	}
-
-	returnType, err := function.ReturnTypeForValues(args)
-	if err != nil {
+	if ret, err = conformResult(function.name, ret, returnType); err != nil {
 		return nil, err
 	}
+	return msgpack.Marshal(ret, returnType)
+}
 
-	result, err := msgpack.Marshal(ret, returnType)
-	if err != nil {
+func (g *GrpcServer) StreamLogs(request *proto.StreamLogsRequest, stream proto.Plugin_StreamLogsServer) error {
+	level := hclog.Trace
+	if len(request.Level) > 0 {
+		level = hclog.LevelFromString(request.Level)
+		if level == hclog.NoLevel {
+			return fmt.Errorf("invalid log level %q", request.Level)
+		}
+	}
+
+	g.init()
+	records, unsubscribe := g.logs.subscribe(level)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case record := <-records:
+			if err := stream.Send(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (g *GrpcServer) GetMetrics(context.Context, *proto.GetMetricsRequest) (*proto.GetMetricsResponse, error) {
+	g.init()
+
+	var metrics bytes.Buffer
+	if _, err := g.metrics.WriteTo(&metrics); err != nil {
 		return nil, err
 	}
+	return &proto.GetMetricsResponse{
+		Metrics: metrics.Bytes(),
+	}, nil
+}
 
-	return &proto.ExecuteFunctionResponse{
-		Result: result,
+func (g *GrpcServer) GetMetadata(context.Context, *proto.GetMetadataRequest) (*proto.GetMetadataResponse, error) {
+	frameworkVersion, buildInfo := readBuildInfo()
+	return &proto.GetMetadataResponse{
+		Name:             g.name,
+		Version:          g.version,
+		ProtocolVersion:  LatestProtocolVersion,
+		FrameworkVersion: frameworkVersion,
+		BuildInfo:        buildInfo,
 	}, nil
 }
+
+// withHost returns ctx with the host connected during Setup, if any.
+func (g *GrpcServer) withHost(ctx context.Context) context.Context {
+	g.hostMutex.Lock()
+	defer g.hostMutex.Unlock()
+
+	if g.host == nil {
+		return ctx
+	}
+	return ContextWithHost(ctx, g.host)
+}
+
+func (g *GrpcServer) logCache(name string, hit bool, cache *cache) {
+	stats := cache.stats()
+	message := "function cache miss"
+	if hit {
+		message = "function cache hit"
+	}
+	g.logger.Debug(message, "function", name, "hits", stats.Hits, "misses", stats.Misses, "entries", stats.Entries, "bytes", stats.Bytes)
+}
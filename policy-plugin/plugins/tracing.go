@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc/metadata"
+)
+
+// tracerName identifies the spans created by the framework.
+const tracerName = "github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+
+// propagator reads the W3C trace context and baggage the host attaches to the
+// gRPC metadata of each request.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// WithTracerProvider sets the provider of the tracer used to record a span for
+// each function execution. This is mostly useful in tests, together with an
+// in-memory exporter. By default, no spans are recorded.
+//
+// It can't be combined with WithTraceFile or WithTraceEndpoint, which create a
+// provider of their own, and the plugin fails to start if it is.
+func WithTracerProvider(provider trace.TracerProvider) ServeOption {
+	return func(config *serveConfig) {
+		config.tracerProvider = provider
+	}
+}
+
+// WithTraceFile writes the spans recorded for each function execution as JSON
+// to the file at path, which is truncated when the plugin starts.
+func WithTraceFile(path string) ServeOption {
+	return func(config *serveConfig) {
+		config.traceFile = path
+	}
+}
+
+// WithTraceEndpoint exports the spans recorded for each function execution to
+// the OTLP gRPC collector at endpoint, such as "localhost:4317". The standard
+// OTEL_EXPORTER_OTLP_* environment variables configure the connection.
+func WithTraceEndpoint(endpoint string) ServeOption {
+	return func(config *serveConfig) {
+		config.traceEndpoint = endpoint
+	}
+}
+
+// startTracing creates the tracer provider for any configured exporters, and
+// returns a function that flushes and stops it.
+func (config *serveConfig) startTracing(ctx context.Context) (func(), error) {
+	if config.tracerProvider != nil && (len(config.traceFile) > 0 || len(config.traceEndpoint) > 0) {
+		return nil, errors.New("a tracer provider can't be combined with a trace file or endpoint")
+	}
+
+	var opts []sdktrace.TracerProviderOption
+	var files []*os.File
+	closeFiles := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	if len(config.traceFile) > 0 {
+		file, err := os.Create(config.traceFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			closeFiles()
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	if len(config.traceEndpoint) > 0 {
+		exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(config.traceEndpoint))
+		if err != nil {
+			closeFiles()
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	if len(opts) == 0 {
+		return func() {}, nil
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	config.tracerProvider = provider
+	return func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			config.logger.Error("failed to flush traces", "error", err)
+		}
+		closeFiles()
+	}, nil
+}
+
+func (config *serveConfig) tracer() trace.Tracer {
+	if config.tracerProvider == nil {
+		return noop.NewTracerProvider().Tracer(tracerName)
+	}
+	return config.tracerProvider.Tracer(tracerName)
+}
+
+// extractTraceContext returns ctx with the span context propagated by the host
+// through the gRPC metadata, if any.
+func extractTraceContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	carrier := make(propagation.MapCarrier)
+	for _, key := range propagator.Fields() {
+		if values := md.Get(key); len(values) > 0 {
+			carrier[key] = values[0]
+		}
+	}
+	return propagator.Extract(ctx, carrier)
+}
+
+// startSpan starts a child span of the span in ctx, and returns a function that
+// ends it while recording err, if any.
+func startSpan(ctx context.Context, tracer trace.Tracer, name string) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(ctx, name)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestExecuteFunction_Tracing(t *testing.T) {
+	RegisterFunction("traced", func(s string) (string, error) {
+		return s, nil
+	})
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	config := newServeConfig([]ServeOption{WithTracerProvider(provider)})
+	server := &GrpcServer{tracer: config.tracer()}
+
+	argument, err := msgpack.Marshal(cty.StringVal("hello"), cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	if _, err := server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+		Name:      "traced",
+		Arguments: [][]byte{argument},
+	}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	spans := exporter.GetSpans()
+	names := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		names[span.Name] = span
+	}
+
+	root, ok := names["ExecuteFunction traced"]
+	if !ok {
+		t.Fatalf("missing execution span, got %v", spans)
+	}
+	if traceID := root.SpanContext.TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the propagated trace ID, got %s", traceID)
+	}
+	if parent := root.Parent.SpanID().String(); parent != "00f067aa0ba902b7" {
+		t.Errorf("expected the propagated parent span, got %s", parent)
+	}
+
+	for _, name := range []string{"convert arguments", "call function", "convert result"} {
+		span, ok := names[name]
+		if !ok {
+			t.Errorf("missing %q span", name)
+			continue
+		}
+		if span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("expected %q to be a child of the execution span", name)
+		}
+	}
+}
+
+func TestServe_StopsTracingOnError(t *testing.T) {
+	// Occupy the metrics address, so that serving fails after tracing started.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	config := newServeConfig([]ServeOption{
+		WithTraceFile(filepath.Join(t.TempDir(), "traces.json")),
+		WithMetricsAddress(listener.Addr().String()),
+	})
+	if err := serve(config); err == nil || !strings.Contains(err.Error(), "failed to serve metrics") {
+		t.Fatalf("expected a metrics error, got %v", err)
+	}
+
+	if _, span := config.tracer().Start(context.Background(), "after"); span.IsRecording() {
+		t.Errorf("expected the tracer provider to be shut down")
+	}
+}
+
+func TestServe_TracerProviderConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	config := newServeConfig([]ServeOption{
+		WithTracerProvider(sdktrace.NewTracerProvider()),
+		WithTraceFile(path),
+	})
+	if _, err := config.startTracing(context.Background()); err == nil {
+		t.Fatalf("expected an error combining a tracer provider with a trace file")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the trace file not to be created, got %v", err)
+	}
+}
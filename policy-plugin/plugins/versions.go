@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+const (
+	// ProtocolVersion1 is the original protocol, where ExecuteFunction reports
+	// any failure as a gRPC error.
+	ProtocolVersion1 = 1
+
+	// ProtocolVersion2 reports failures within ExecuteFunction as error
+	// diagnostics, so hosts can tell them apart from transport errors.
+	ProtocolVersion2 = 2
+
+	// LatestProtocolVersion is the newest protocol version the framework
+	// supports.
+	LatestProtocolVersion = ProtocolVersion2
+)
+
+var (
+	_ proto.PluginServer = (*grpcServerV1)(nil)
+)
+
+// versionedPlugins returns the plugin set for every supported protocol
+// version. go-plugin serves the newest version the host also supports, or the
+// oldest version to hosts that don't negotiate.
+func versionedPlugins(config *serveConfig) map[int]plugin.PluginSet {
+	sets := make(map[int]plugin.PluginSet)
+	for _, version := range []int{ProtocolVersion1, ProtocolVersion2} {
+		sets[version] = plugin.PluginSet{
+			"plugin": &PluginServer{config: config, protocolVersion: version},
+		}
+	}
+	return sets
+}
+
+// ClientPlugins returns the plugin sets hosts should pass to go-plugin as
+// VersionedPlugins, so the newest protocol version supported by both the host
+// and the plugin is negotiated.
+func ClientPlugins() map[int]plugin.PluginSet {
+	return versionedPlugins(nil)
+}
+
+// grpcServerV1 adapts the latest server to protocol version 1, by converting
+// error diagnostics back into gRPC errors.
+type grpcServerV1 struct {
+	*GrpcServer
+}
+
+func (g *grpcServerV1) ExecuteFunction(ctx context.Context, request *proto.ExecuteFunctionRequest) (*proto.ExecuteFunctionResponse, error) {
+	response, err := g.GrpcServer.ExecuteFunction(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, diagnostic := range response.Diagnostics {
+		if diagnostic.Severity == proto.Diagnostic_ERROR {
+			return nil, errors.New(diagnostic.Detail)
+		}
+	}
+	return response, nil
+}
+
+func (g *grpcServerV1) GetMetadata(ctx context.Context, request *proto.GetMetadataRequest) (*proto.GetMetadataResponse, error) {
+	response, err := g.GrpcServer.GetMetadata(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	response.ProtocolVersion = ProtocolVersion1
+	return response, nil
+}
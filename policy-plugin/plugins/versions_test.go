@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestProtocolVersions(t *testing.T) {
+	RegisterFunction("versioned", func(mode string) (string, error) {
+		switch mode {
+		case "fail":
+			return "", errors.New("failed on purpose")
+		case "panic":
+			panic("panicked on purpose")
+		}
+		return mode, nil
+	})
+
+	type expectation struct {
+		err        string
+		diagnostic string
+	}
+
+	tcs := map[string]struct {
+		name      string
+		arguments []string
+		expected  map[int]expectation
+	}{
+		"success": {
+			name:      "versioned",
+			arguments: []string{"ok"},
+			expected: map[int]expectation{
+				ProtocolVersion1: {},
+				ProtocolVersion2: {},
+			},
+		},
+		"function error": {
+			name:      "versioned",
+			arguments: []string{"fail"},
+			expected: map[int]expectation{
+				ProtocolVersion1: {err: "failed on purpose"},
+				ProtocolVersion2: {diagnostic: "Error in function call: failed on purpose"},
+			},
+		},
+		"not found": {
+			name: "missing",
+			expected: map[int]expectation{
+				ProtocolVersion1: {err: `function "missing" not found`},
+				ProtocolVersion2: {diagnostic: `Function not found: function "missing" not found`},
+			},
+		},
+		"too many arguments": {
+			name:      "versioned",
+			arguments: []string{"ok", "extra"},
+			expected: map[int]expectation{
+				ProtocolVersion1: {err: "too many arguments"},
+				ProtocolVersion2: {diagnostic: "Invalid function arguments: too many arguments"},
+			},
+		},
+		"panic": {
+			name:      "versioned",
+			arguments: []string{"panic"},
+			expected: map[int]expectation{
+				ProtocolVersion1: {err: "panicked on purpose"},
+				ProtocolVersion2: {diagnostic: "Internal error in plugin function"},
+			},
+		},
+	}
+
+	for version, plugins := range versionedPlugins(newServeConfig(nil)) {
+		client, _ := plugin.TestPluginGRPCConn(t, false, plugins)
+		raw, err := client.Dispense("plugin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := raw.(proto.PluginClient)
+
+		metadata, err := server.GetMetadata(context.Background(), new(proto.GetMetadataRequest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.ProtocolVersion != uint32(version) {
+			t.Errorf("v%d: expected metadata to report the served version, got %d", version, metadata.ProtocolVersion)
+		}
+
+		for name, tc := range tcs {
+			var arguments [][]byte
+			for _, argument := range tc.arguments {
+				encoded, err := msgpack.Marshal(cty.StringVal(argument), cty.String)
+				if err != nil {
+					t.Fatal(err)
+				}
+				arguments = append(arguments, encoded)
+			}
+
+			response, err := server.ExecuteFunction(context.Background(), &proto.ExecuteFunctionRequest{
+				Name:      tc.name,
+				Arguments: arguments,
+			})
+
+			expected := tc.expected[version]
+			switch {
+			case len(expected.err) > 0:
+				if err == nil || !strings.Contains(err.Error(), expected.err) {
+					t.Errorf("v%d %s: expected error containing %q, got %v", version, name, expected.err, err)
+				}
+			case err != nil:
+				t.Errorf("v%d %s: unexpected error: %s", version, name, err)
+			case len(expected.diagnostic) > 0:
+				if len(response.Diagnostics) != 1 || response.Diagnostics[0].Severity != proto.Diagnostic_ERROR {
+					t.Errorf("v%d %s: expected exactly one error diagnostic, got %v", version, name, response.Diagnostics)
+				} else if diagnostic := response.Diagnostics[0]; !strings.HasPrefix(diagnostic.Summary+": "+diagnostic.Detail, expected.diagnostic) {
+					t.Errorf("v%d %s: expected diagnostic %q, got %v", version, name, expected.diagnostic, diagnostic)
+				}
+			default:
+				if len(response.Diagnostics) > 0 || len(response.Result) == 0 {
+					t.Errorf("v%d %s: expected a result without diagnostics, got %v", version, name, response)
+				}
+			}
+		}
+
+		client.Close()
+	}
+}
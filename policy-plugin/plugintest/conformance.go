@@ -0,0 +1,370 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugintest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// The functions RunConformance expects the server to provide. Servers that
+// aren't built with this framework must implement them exactly as described.
+const (
+	// ConformanceEcho returns its string argument.
+	ConformanceEcho = "conformance::echo"
+
+	// ConformanceJoin joins its variadic string arguments with its first
+	// argument as the separator.
+	ConformanceJoin = "conformance::join"
+
+	// ConformanceOptional returns its string argument, which can be null, or
+	// "<null>" if it is null.
+	ConformanceOptional = "conformance::optional"
+
+	// ConformanceFail fails with its string argument as the error message.
+	ConformanceFail = "conformance::fail"
+
+	// ConformanceTypes accepts a bool, a number, a string, a list of strings, a
+	// map of numbers and an object with a string name attribute, in that order,
+	// and returns them as an object with the attributes flag, count, text,
+	// items, weights and owner. The collections can be null.
+	ConformanceTypes = "conformance::types"
+)
+
+var (
+	conformanceOnce sync.Once
+
+	conformanceOwnerType = cty.Object(map[string]cty.Type{
+		"name": cty.String,
+	})
+	conformanceParameters = []cty.Type{
+		cty.Bool,
+		cty.Number,
+		cty.String,
+		cty.List(cty.String),
+		cty.Map(cty.Number),
+		conformanceOwnerType,
+	}
+	conformanceResultType = cty.Object(map[string]cty.Type{
+		"flag":    cty.Bool,
+		"count":   cty.Number,
+		"text":    cty.String,
+		"items":   cty.List(cty.String),
+		"weights": cty.Map(cty.Number),
+		"owner":   conformanceOwnerType,
+	})
+)
+
+type conformanceOwner struct {
+	Name string `cty:"name"`
+}
+
+type conformanceResult struct {
+	Flag    bool               `cty:"flag"`
+	Count   float64            `cty:"count"`
+	Text    string             `cty:"text"`
+	Items   []string           `cty:"items"`
+	Weights map[string]float64 `cty:"weights"`
+	Owner   conformanceOwner   `cty:"owner"`
+}
+
+// RegisterConformanceFunctions registers the functions RunConformance expects
+// with the framework. It is safe to call more than once.
+func RegisterConformanceFunctions() {
+	conformanceOnce.Do(func() {
+		plugins.RegisterFunction(ConformanceEcho, func(value string) (string, error) {
+			return value, nil
+		})
+		plugins.RegisterFunction(ConformanceJoin, func(separator string, values ...string) (string, error) {
+			return strings.Join(values, separator), nil
+		})
+		plugins.RegisterFunction(ConformanceOptional, func(value *string) (string, error) {
+			if value == nil {
+				return "<null>", nil
+			}
+			return *value, nil
+		})
+		plugins.RegisterFunction(ConformanceFail, func(message string) (string, error) {
+			return "", errors.New(message)
+		})
+		plugins.RegisterFunction(ConformanceTypes, func(flag bool, count float64, text string, items []string, weights map[string]float64, owner conformanceOwner) (conformanceResult, error) {
+			return conformanceResult{
+				Flag:    flag,
+				Count:   count,
+				Text:    text,
+				Items:   items,
+				Weights: weights,
+				Owner:   owner,
+			}, nil
+		})
+	})
+}
+
+// RunConformance verifies that server implements the plugin protocol the way
+// hosts expect, using the functions described by the Conformance constants.
+// Plugins built with this framework must call RegisterConformanceFunctions
+// first.
+func RunConformance(t *testing.T, server proto.PluginServer) {
+	t.Helper()
+
+	ctx := context.Background()
+	suite := &conformance{server: server}
+
+	t.Run("Setup", func(t *testing.T) {
+		if _, err := server.Setup(ctx, new(proto.PluginSetupRequest)); err != nil {
+			t.Fatalf("unexpected error setting up: %s", err)
+		}
+		if _, err := server.Setup(ctx, &proto.PluginSetupRequest{
+			ClientCapabilities: new(proto.PluginSetupRequest_ClientCapabilities),
+		}); err != nil {
+			t.Errorf("expected setting up again to succeed, got %s", err)
+		}
+	})
+
+	t.Run("ListFunctions", func(t *testing.T) {
+		response, err := server.ListFunctions(ctx, new(proto.ListFunctionsRequest))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		expected := map[string]struct {
+			parameters []cty.Type
+			variadic   cty.Type
+			returns    cty.Type
+
+			// nullable lists which parameters allow null, and is nil if none do.
+			nullable []bool
+		}{
+			ConformanceEcho:     {parameters: []cty.Type{cty.String}, returns: cty.String},
+			ConformanceJoin:     {parameters: []cty.Type{cty.String}, variadic: cty.String, returns: cty.String},
+			ConformanceOptional: {parameters: []cty.Type{cty.String}, returns: cty.String, nullable: []bool{true}},
+			ConformanceFail:     {parameters: []cty.Type{cty.String}, returns: cty.String},
+			ConformanceTypes:    {parameters: conformanceParameters, returns: conformanceResultType, nullable: []bool{false, false, false, true, true, false}},
+		}
+		for name, want := range expected {
+			fn, ok := response.Functions[name]
+			if !ok {
+				t.Errorf("expected function %s", name)
+				continue
+			}
+
+			// The definition must survive the conversion hosts make.
+			converted, err := fn.ToCtyFunction(func([]cty.Value, cty.Type) (cty.Value, error) {
+				return cty.NilVal, errors.New("not implemented")
+			})
+			if err != nil {
+				t.Errorf("invalid definition for %s: %s", name, err)
+				continue
+			}
+
+			params := converted.Params()
+			if len(params) != len(want.parameters) {
+				t.Errorf("expected %s to have %d parameters, got %d", name, len(want.parameters), len(params))
+				continue
+			}
+			for ix, param := range params {
+				if !param.Type.Equals(want.parameters[ix]) {
+					t.Errorf("expected parameter %d of %s to be %s, got %s", ix, name, want.parameters[ix].GoString(), param.Type.GoString())
+				}
+				if nullable := ix < len(want.nullable) && want.nullable[ix]; param.AllowNull != nullable {
+					t.Errorf("expected parameter %d of %s to have allow_null %t, got %t", ix, name, nullable, param.AllowNull)
+				}
+			}
+
+			variadic := converted.VarParam()
+			switch {
+			case want.variadic == cty.NilType && variadic != nil:
+				t.Errorf("expected %s not to be variadic", name)
+			case want.variadic != cty.NilType && (variadic == nil || !variadic.Type.Equals(want.variadic)):
+				t.Errorf("expected %s to have a variadic %s parameter", name, want.variadic.GoString())
+			}
+
+			returns, err := ctyjson.UnmarshalType(fn.ReturnType)
+			if err != nil || !returns.Equals(want.returns) {
+				t.Errorf("expected %s to return %s, got %s (%v)", name, want.returns.GoString(), fn.ReturnType, err)
+			}
+		}
+	})
+
+	t.Run("Arguments", func(t *testing.T) {
+		tcs := map[string]struct {
+			name     string
+			args     []cty.Value
+			expected string
+			err      bool
+		}{
+			"exact":                   {name: ConformanceEcho, args: []cty.Value{cty.StringVal("hello")}, expected: "hello"},
+			"too few":                 {name: ConformanceEcho, err: true},
+			"too many":                {name: ConformanceEcho, args: []cty.Value{cty.StringVal("a"), cty.StringVal("b")}, err: true},
+			"no variadic arguments":   {name: ConformanceJoin, args: []cty.Value{cty.StringVal(",")}, expected: ""},
+			"one variadic argument":   {name: ConformanceJoin, args: []cty.Value{cty.StringVal(","), cty.StringVal("a")}, expected: "a"},
+			"many variadic arguments": {name: ConformanceJoin, args: []cty.Value{cty.StringVal(","), cty.StringVal("a"), cty.StringVal("b"), cty.StringVal("c")}, expected: "a,b,c"},
+			"missing fixed argument":  {name: ConformanceJoin, err: true},
+			"unicode":                 {name: ConformanceEcho, args: []cty.Value{cty.StringVal("héllo, 世界")}, expected: "héllo, 世界"},
+		}
+		for name, tc := range tcs {
+			t.Run(name, func(t *testing.T) {
+				result, err := suite.execute(ctx, tc.name, tc.args, cty.String)
+				if tc.err {
+					if err == nil {
+						t.Errorf("expected an error, got %#v", result)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if !result.RawEquals(cty.StringVal(tc.expected)) {
+					t.Errorf("expected %q, got %#v", tc.expected, result)
+				}
+			})
+		}
+	})
+
+	t.Run("Types", func(t *testing.T) {
+		args := []cty.Value{
+			cty.True,
+			cty.NumberFloatVal(1.5),
+			cty.StringVal("text"),
+			cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			cty.MapVal(map[string]cty.Value{"x": cty.NumberIntVal(3)}),
+			cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("owner")}),
+		}
+		result, err := suite.execute(ctx, ConformanceTypes, args, conformanceResultType)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		expected := cty.ObjectVal(map[string]cty.Value{
+			"flag":    args[0],
+			"count":   args[1],
+			"text":    args[2],
+			"items":   args[3],
+			"weights": args[4],
+			"owner":   args[5],
+		})
+		if !result.Equals(expected).True() {
+			t.Errorf("expected %#v, got %#v", expected, result)
+		}
+	})
+
+	t.Run("Nulls", func(t *testing.T) {
+		result, err := suite.execute(ctx, ConformanceOptional, []cty.Value{cty.NullVal(cty.String)}, cty.String)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !result.RawEquals(cty.StringVal("<null>")) {
+			t.Errorf("expected null to be passed to the function, got %#v", result)
+		}
+
+		if _, err := suite.execute(ctx, ConformanceEcho, []cty.Value{cty.NullVal(cty.String)}, cty.String); err == nil {
+			t.Errorf("expected null to be rejected for parameters that don't allow it")
+		}
+
+		args := []cty.Value{
+			cty.False,
+			cty.Zero,
+			cty.StringVal(""),
+			cty.NullVal(cty.List(cty.String)),
+			cty.NullVal(cty.Map(cty.Number)),
+			cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("")}),
+		}
+		result, err = suite.execute(ctx, ConformanceTypes, args, conformanceResultType)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !result.GetAttr("items").IsNull() || !result.GetAttr("weights").IsNull() {
+			t.Errorf("expected null collections to be returned as null, got %#v", result)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := suite.execute(ctx, ConformanceFail, []cty.Value{cty.StringVal("failed on purpose")}, cty.String); err == nil || !strings.Contains(err.Error(), "failed on purpose") {
+			t.Errorf("expected the error of the function, got %v", err)
+		}
+		if _, err := suite.execute(ctx, "conformance::missing", nil, cty.String); err == nil {
+			t.Errorf("expected an error calling a missing function")
+		}
+		response, err := server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+			Name:      ConformanceEcho,
+			Arguments: [][]byte{[]byte("not msgpack")},
+		})
+		if err == nil && !hasError(response.Diagnostics) {
+			t.Errorf("expected an error for invalid arguments")
+		}
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for ix := 0; ix < 64; ix++ {
+			wg.Add(1)
+			go func(ix int) {
+				defer wg.Done()
+
+				value := fmt.Sprintf("call %d", ix)
+				result, err := suite.execute(ctx, ConformanceEcho, []cty.Value{cty.StringVal(value)}, cty.String)
+				if err != nil {
+					t.Errorf("unexpected error in call %d: %s", ix, err)
+					return
+				}
+				if !result.RawEquals(cty.StringVal(value)) {
+					t.Errorf("expected %q, got %#v", value, result)
+				}
+			}(ix)
+		}
+		wg.Wait()
+	})
+}
+
+type conformance struct {
+	server proto.PluginServer
+}
+
+// execute calls the function with args, which must have the types the server
+// declares for them, and returns the result decoded as returns. Failures
+// reported as gRPC errors and as error diagnostics are both returned as errors,
+// so the suite accepts every protocol version.
+func (c *conformance) execute(ctx context.Context, name string, args []cty.Value, returns cty.Type) (cty.Value, error) {
+	arguments := make([][]byte, len(args))
+	for ix, arg := range args {
+		encoded, err := msgpack.Marshal(arg, arg.Type())
+		if err != nil {
+			return cty.NilVal, function.NewArgError(ix, err)
+		}
+		arguments[ix] = encoded
+	}
+
+	response, err := c.server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+		Name:      name,
+		Arguments: arguments,
+	})
+	if err != nil {
+		return cty.NilVal, err
+	}
+	if err := diagnosticsError(response.Diagnostics); err != nil {
+		return cty.NilVal, err
+	}
+	return msgpack.Unmarshal(response.Result, returns)
+}
+
+func hasError(diags []*proto.Diagnostic) bool {
+	for _, diag := range diags {
+		if diag.Severity == proto.Diagnostic_ERROR {
+			return true
+		}
+	}
+	return false
+}
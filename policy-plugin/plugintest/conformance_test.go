@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugintest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+func TestConformance(t *testing.T) {
+	RegisterConformanceFunctions()
+	RunConformance(t, new(plugins.GrpcServer))
+}
+
+// TestConformance_ProtocolVersions runs the suite over a gRPC connection for
+// every protocol version, including the adapters for older versions.
+func TestConformance_ProtocolVersions(t *testing.T) {
+	RegisterConformanceFunctions()
+	for version, set := range plugins.ClientPlugins() {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			conn, server := plugin.TestPluginGRPCConn(t, false, set)
+			defer conn.Close()
+			defer server.Stop()
+
+			raw, err := conn.Dispense("plugin")
+			if err != nil {
+				t.Fatalf("failed to dispense the plugin: %s", err)
+			}
+			client := raw.(*plugins.PluginClient)
+
+			metadata, err := client.GetMetadata(context.Background(), new(proto.GetMetadataRequest))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if int(metadata.ProtocolVersion) != version {
+				t.Fatalf("expected protocol version %d to be served, got %d", version, metadata.ProtocolVersion)
+			}
+
+			RunConformance(t, &clientServer{client: client})
+		})
+	}
+}
+
+// clientServer implements the calls the suite makes by forwarding them to a
+// client.
+type clientServer struct {
+	proto.UnimplementedPluginServer
+
+	client proto.PluginClient
+}
+
+func (s *clientServer) Setup(ctx context.Context, request *proto.PluginSetupRequest) (*proto.PluginSetupResponse, error) {
+	return s.client.Setup(ctx, request, grpc.WaitForReady(true))
+}
+
+func (s *clientServer) ListFunctions(ctx context.Context, request *proto.ListFunctionsRequest) (*proto.ListFunctionsResponse, error) {
+	return s.client.ListFunctions(ctx, request)
+}
+
+func (s *clientServer) ExecuteFunction(ctx context.Context, request *proto.ExecuteFunctionRequest) (*proto.ExecuteFunctionResponse, error) {
+	return s.client.ExecuteFunction(ctx, request)
+}
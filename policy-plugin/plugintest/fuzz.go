@@ -0,0 +1,372 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+var (
+	// fuzzNumbers are the numbers the generator prefers over arbitrary ones.
+	fuzzNumbers = []cty.Value{
+		cty.Zero,
+		cty.NumberIntVal(-1),
+		cty.NumberIntVal(math.MaxInt64),
+		cty.NumberIntVal(math.MinInt64),
+		cty.NumberFloatVal(0.1),
+		cty.NumberFloatVal(-math.SmallestNonzeroFloat64),
+		cty.NumberFloatVal(math.MaxFloat64),
+		cty.NumberVal(new(big.Float).SetMantExp(big.NewFloat(1), 200)),
+	}
+
+	// fuzzStrings are the strings the generator prefers over arbitrary ones.
+	fuzzStrings = []string{
+		"",
+		" ",
+		"héllo, 世界",
+		"🙂👩‍👩‍👧",
+		"\x00",
+		"\u202eevil",
+		"${var.value}",
+		strings.Repeat("long ", 4096),
+	}
+
+	// fuzzTypes are the types the generator picks from for dynamic values.
+	fuzzTypes = []cty.Type{
+		cty.String,
+		cty.Number,
+		cty.Bool,
+		cty.List(cty.String),
+		cty.Map(cty.Number),
+		cty.Object(map[string]cty.Type{"name": cty.String}),
+	}
+)
+
+// FuzzFunction fuzzes the named function of server with Go's native fuzzing.
+// Each input is turned into arguments that conform to the parameters of the
+// function by a Generator, and the test fails if the function panics, if the
+// server rejects the arguments, or if the result doesn't conform to the return
+// type the function declares. Errors returned by the function are expected.
+//
+//	func FuzzJoin(f *testing.F) {
+//		plugintest.FuzzFunction(f, new(plugins.GrpcServer), "join")
+//	}
+func FuzzFunction(f *testing.F, server proto.PluginServer, name string) {
+	f.Helper()
+
+	fuzzer, err := newFuzzer(context.Background(), server, name)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add([]byte{})
+	f.Add(bytes.Repeat([]byte{0xff}, 64))
+	seed := make([]byte, 256)
+	for ix := range seed {
+		seed[ix] = byte(ix)
+	}
+	f.Add(seed)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		args := fuzzer.arguments(NewGenerator(data))
+		if err := fuzzer.check(context.Background(), args); err != nil {
+			t.Fatalf("%s with arguments %#v: %s", name, args, err)
+		}
+	})
+}
+
+// fuzzer checks the contract of a single function of a server.
+type fuzzer struct {
+	server   proto.PluginServer
+	name     string
+	fn       *proto.Function
+	params   []cty.Type
+	nullable []bool
+	variadic *cty.Type
+}
+
+func newFuzzer(ctx context.Context, server proto.PluginServer, name string) (*fuzzer, error) {
+	response, err := server.ListFunctions(ctx, new(proto.ListFunctionsRequest))
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := response.Functions[name]
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", name)
+	}
+
+	fuzzer := &fuzzer{server: server, name: name, fn: fn}
+	for _, parameter := range fn.Parameters {
+		param, err := parameter.ToCtyParameter()
+		if err != nil {
+			return nil, err
+		}
+		fuzzer.params = append(fuzzer.params, param.Type)
+		fuzzer.nullable = append(fuzzer.nullable, param.AllowNull)
+	}
+	if fn.VariadicParameter != nil {
+		param, err := fn.VariadicParameter.ToCtyParameter()
+		if err != nil {
+			return nil, err
+		}
+		fuzzer.variadic = &param.Type
+	}
+	return fuzzer, nil
+}
+
+// arguments generates the arguments for a single call. Only parameters that
+// allow null get null arguments, as the server rightly rejects them for the
+// others.
+func (f *fuzzer) arguments(gen *Generator) []cty.Value {
+	var args []cty.Value
+	for ix, ty := range f.params {
+		if f.nullable[ix] {
+			args = append(args, gen.Value(ty))
+		} else {
+			args = append(args, gen.KnownValue(ty))
+		}
+	}
+	if f.variadic != nil {
+		for count := gen.next() % 4; count > 0; count-- {
+			args = append(args, gen.KnownValue(*f.variadic))
+		}
+	}
+	return args
+}
+
+// check calls the function with args through the server, and returns an error
+// if the call breaks the contract of the function.
+func (f *fuzzer) check(ctx context.Context, args []cty.Value) error {
+	arguments := make([][]byte, len(args))
+	types := make([][]byte, len(args))
+	for ix, arg := range args {
+		ty := f.parameterType(ix)
+
+		var err error
+		if arguments[ix], err = msgpack.Marshal(arg, ty); err != nil {
+			return fmt.Errorf("invalid argument %d: %w", ix, err)
+		}
+		if types[ix], err = ctyjson.MarshalType(arg.Type()); err != nil {
+			return err
+		}
+	}
+
+	returnType := f.fn.ReturnType
+	if f.fn.DynamicReturnType {
+		response, err := f.server.GetReturnType(ctx, &proto.GetReturnTypeRequest{
+			Name:          f.name,
+			ArgumentTypes: types,
+		})
+		if err != nil {
+			return err
+		}
+		if hasError(response.Diagnostics) {
+			// The function doesn't accept arguments of these types.
+			return nil
+		}
+		returnType = response.ReturnType
+	}
+
+	response, err := f.server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+		Name:      f.name,
+		Arguments: arguments,
+	})
+	if err != nil {
+		return err
+	}
+	for _, diag := range response.Diagnostics {
+		if diag.Severity != proto.Diagnostic_ERROR {
+			continue
+		}
+		if diag.Summary == "Error in function call" {
+			return nil
+		}
+		return fmt.Errorf("%s: %s", diag.Summary, diag.Detail)
+	}
+
+	ty, err := ctyjson.UnmarshalType(returnType)
+	if err != nil {
+		return fmt.Errorf("invalid return type: %w", err)
+	}
+	result, err := msgpack.Unmarshal(response.Result, ty)
+	if err != nil {
+		return fmt.Errorf("result doesn't conform to the return type %s: %w", ty.FriendlyName(), err)
+	}
+	if errs := result.Type().TestConformance(ty); len(errs) > 0 {
+		return fmt.Errorf("result doesn't conform to the return type %s: %s", ty.FriendlyName(), errs[0])
+	}
+	return nil
+}
+
+func (f *fuzzer) parameterType(ix int) cty.Type {
+	if ix < len(f.params) {
+		return f.params[ix]
+	}
+	return *f.variadic
+}
+
+// Generator deterministically turns fuzzer input into cty values of a given
+// type. It favours the values most likely to break conversions, like nulls,
+// empty collections, large numbers and unicode strings, and produces zero
+// values once the input runs out.
+type Generator struct {
+	data []byte
+}
+
+// NewGenerator returns a Generator that consumes data.
+func NewGenerator(data []byte) *Generator {
+	return &Generator{data: data}
+}
+
+// Value returns a value of type ty, which may be null.
+func (g *Generator) Value(ty cty.Type) cty.Value {
+	if g.next()%8 == 7 {
+		if ty == cty.DynamicPseudoType {
+			return cty.NullVal(g.dynamicType())
+		}
+		return cty.NullVal(ty)
+	}
+	return g.KnownValue(ty)
+}
+
+// KnownValue returns a value of type ty that isn't null itself, but may contain
+// nulls.
+func (g *Generator) KnownValue(ty cty.Type) cty.Value {
+	switch {
+	case ty == cty.DynamicPseudoType:
+		return g.KnownValue(g.dynamicType())
+	case ty == cty.Bool:
+		return cty.BoolVal(g.next()%2 == 1)
+	case ty == cty.Number:
+		return g.number()
+	case ty == cty.String:
+		return cty.StringVal(g.string())
+	case ty.IsListType():
+		elements := g.elements(ty.ElementType(), true)
+		if len(elements) == 0 {
+			return cty.ListValEmpty(ty.ElementType())
+		}
+		return cty.ListVal(elements)
+	case ty.IsSetType():
+		// Nulls in sets are rarely meaningful, so they're left out.
+		elements := g.elements(ty.ElementType(), false)
+		if len(elements) == 0 {
+			return cty.SetValEmpty(ty.ElementType())
+		}
+		return cty.SetVal(elements)
+	case ty.IsMapType():
+		elements := make(map[string]cty.Value)
+		for _, element := range g.elements(ty.ElementType(), true) {
+			elements[g.string()] = element
+		}
+		if len(elements) == 0 {
+			return cty.MapValEmpty(ty.ElementType())
+		}
+		return cty.MapVal(elements)
+	case ty.IsTupleType():
+		elements := make([]cty.Value, len(ty.TupleElementTypes()))
+		for ix, element := range ty.TupleElementTypes() {
+			elements[ix] = g.Value(element)
+		}
+		return cty.TupleVal(elements)
+	case ty.IsObjectType():
+		// Attributes are visited in order, so the same input always
+		// produces the same value.
+		names := make([]string, 0, len(ty.AttributeTypes()))
+		for name := range ty.AttributeTypes() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		attributes := make(map[string]cty.Value)
+		for _, name := range names {
+			attributes[name] = g.Value(ty.AttributeType(name))
+		}
+		return cty.ObjectVal(attributes)
+	default:
+		return cty.NullVal(ty)
+	}
+}
+
+// elements returns up to four elements of type ty. Collections can't mix
+// types, so a dynamic element type is resolved once for all the elements.
+func (g *Generator) elements(ty cty.Type, nulls bool) []cty.Value {
+	if ty == cty.DynamicPseudoType {
+		ty = g.dynamicType()
+	}
+
+	elements := make([]cty.Value, g.next()%5)
+	for ix := range elements {
+		if nulls {
+			elements[ix] = g.Value(ty)
+		} else {
+			elements[ix] = g.KnownValue(ty)
+		}
+	}
+	return elements
+}
+
+func (g *Generator) dynamicType() cty.Type {
+	return fuzzTypes[int(g.next())%len(fuzzTypes)]
+}
+
+func (g *Generator) number() cty.Value {
+	choice := int(g.next())
+	if choice < len(fuzzNumbers)*16 {
+		return fuzzNumbers[choice%len(fuzzNumbers)]
+	}
+
+	bits := binary.LittleEndian.Uint64(g.bytes(8))
+	if choice%2 == 0 {
+		return cty.NumberIntVal(int64(bits))
+	}
+	if value := math.Float64frombits(bits); !math.IsNaN(value) && !math.IsInf(value, 0) {
+		return cty.NumberFloatVal(value)
+	}
+	return cty.Zero
+}
+
+func (g *Generator) string() string {
+	choice := int(g.next())
+	if choice < len(fuzzStrings)*16 {
+		return fuzzStrings[choice%len(fuzzStrings)]
+	}
+	return strings.ToValidUTF8(string(g.bytes(int(g.next()%64))), "�")
+}
+
+// next returns the next byte of the input, or zero once it runs out.
+func (g *Generator) next() byte {
+	if len(g.data) == 0 {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return b
+}
+
+// bytes returns the next n bytes of the input, padded with zeros once it runs
+// out.
+func (g *Generator) bytes(n int) []byte {
+	out := make([]byte, n)
+	copy(out, g.data)
+	if n > len(g.data) {
+		n = len(g.data)
+	}
+	g.data = g.data[n:]
+	return out
+}
@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugintest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+var fuzzTestOnce sync.Once
+
+func registerFuzzTestFunctions() {
+	RegisterConformanceFunctions()
+	fuzzTestOnce.Do(func() {
+		plugins.RegisterFunction("plugintest::fuzz_panic", func(value string) (string, error) {
+			if len(value) == 0 {
+				panic("empty value")
+			}
+			return value, nil
+		})
+	})
+}
+
+func FuzzConformanceTypes(f *testing.F) {
+	registerFuzzTestFunctions()
+	FuzzFunction(f, new(plugins.GrpcServer), ConformanceTypes)
+}
+
+func FuzzConformanceJoin(f *testing.F) {
+	registerFuzzTestFunctions()
+	FuzzFunction(f, new(plugins.GrpcServer), ConformanceJoin)
+}
+
+func TestGenerator(t *testing.T) {
+	types := []cty.Type{
+		cty.Bool,
+		cty.Number,
+		cty.String,
+		cty.DynamicPseudoType,
+		cty.List(cty.String),
+		cty.Set(cty.Number),
+		cty.Map(cty.List(cty.Bool)),
+		cty.List(cty.DynamicPseudoType),
+		cty.Tuple([]cty.Type{cty.String, cty.Number}),
+		conformanceResultType,
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for ix := 0; ix < 500; ix++ {
+		data := make([]byte, random.Intn(128))
+		random.Read(data)
+
+		for _, ty := range types {
+			value := NewGenerator(data).Value(ty)
+			if errs := value.Type().TestConformance(ty); len(errs) > 0 {
+				t.Fatalf("generated %#v for %s: %s", value, ty.GoString(), errs[0])
+			}
+			if again := NewGenerator(data).Value(ty); !again.RawEquals(value) {
+				t.Fatalf("expected the same input to generate the same value, got %#v and %#v", value, again)
+			}
+			if known := NewGenerator(data).KnownValue(ty); known.IsNull() {
+				t.Fatalf("expected a known value for %s, got null", ty.GoString())
+			}
+		}
+	}
+}
+
+func TestFuzzer_Check(t *testing.T) {
+	registerFuzzTestFunctions()
+	ctx := context.Background()
+
+	t.Run("panic", func(t *testing.T) {
+		fuzzer, err := newFuzzer(ctx, new(plugins.GrpcServer), "plugintest::fuzz_panic")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fuzzer.check(ctx, []cty.Value{cty.StringVal("value")}); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if err := fuzzer.check(ctx, []cty.Value{cty.StringVal("")}); err == nil || !strings.Contains(err.Error(), "panicked") {
+			t.Errorf("expected the panic to be reported, got %v", err)
+		}
+	})
+
+	t.Run("function error", func(t *testing.T) {
+		fuzzer, err := newFuzzer(ctx, new(plugins.GrpcServer), ConformanceFail)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fuzzer.check(ctx, []cty.Value{cty.StringVal("failed")}); err != nil {
+			t.Errorf("expected errors of the function to be accepted, got %s", err)
+		}
+	})
+
+	t.Run("result type", func(t *testing.T) {
+		fuzzer, err := newFuzzer(ctx, wrongResultServer{new(plugins.GrpcServer)}, ConformanceEcho)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fuzzer.check(ctx, []cty.Value{cty.StringVal("value")}); err == nil || !strings.Contains(err.Error(), "return type") {
+			t.Errorf("expected the result to be reported, got %v", err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, err := newFuzzer(ctx, new(plugins.GrpcServer), "plugintest::missing"); err == nil {
+			t.Errorf("expected an error for a missing function")
+		}
+	})
+}
+
+// wrongResultServer returns a list for every function.
+type wrongResultServer struct {
+	*plugins.GrpcServer
+}
+
+func (s wrongResultServer) ExecuteFunction(context.Context, *proto.ExecuteFunctionRequest) (*proto.ExecuteFunctionResponse, error) {
+	ty := cty.List(cty.String)
+	result, err := msgpack.Marshal(cty.ListValEmpty(cty.String), ty)
+	if err != nil {
+		return nil, errors.New("failed to encode the result")
+	}
+	return &proto.ExecuteFunctionResponse{Result: result}, nil
+}
@@ -0,0 +1,347 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/proto"
+)
+
+// UpdateEnvVar is the environment variable that makes RunGoldenFiles write the
+// actual results of each case back to its file instead of comparing them. The
+// -update flag does the same, if the test binary defines it.
+const UpdateEnvVar = "TF_POLICY_PLUGIN_UPDATE_GOLDEN"
+
+// GoldenCase is a single function call loaded from a golden file.
+type GoldenCase struct {
+	// Function is the name of the function to call.
+	Function string
+
+	Arguments []cty.Value
+
+	// Result is the expected result, which is converted to the return type of
+	// the function before comparing it.
+	Result cty.Value
+
+	// Error is a substring of the expected error, if the call should fail.
+	Error string
+}
+
+// RunGoldenFiles runs every case in the .hcl and .json files in dir through
+// the framework's server, as a subtest named after the file. Each file holds a
+// single case, with the attributes function, arguments and either result or
+// error:
+//
+//	function  = "join"
+//	arguments = [", ", "a", "b"]
+//	result    = "a, b"
+//
+// The arguments are converted to the types of the parameters of the function,
+// as hosts do. Run the tests with -update, or with UpdateEnvVar set, to write
+// the actual results back to the files.
+func RunGoldenFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read golden files: %s", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if ext := filepath.Ext(entry.Name()); !entry.IsDir() && (ext == ".hcl" || ext == ".json") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		t.Fatalf("no golden files in %s", dir)
+	}
+
+	update := updateGoldenFiles()
+	runner := &goldenRunner{server: new(plugins.GrpcServer)}
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		t.Run(strings.TrimSuffix(file, filepath.Ext(file)), func(t *testing.T) {
+			golden, err := LoadGoldenCase(path)
+			if err != nil {
+				t.Fatalf("failed to load %s: %s", path, err)
+			}
+
+			result, err := runner.call(context.Background(), golden.Function, golden.Arguments)
+
+			if update {
+				if err := writeGoldenCase(path, result, err); err != nil {
+					t.Fatalf("failed to update %s: %s", path, err)
+				}
+				return
+			}
+
+			if len(golden.Error) > 0 {
+				if err == nil || !strings.Contains(err.Error(), golden.Error) {
+					t.Errorf("expected an error containing %q, got %v", golden.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			expected, err := ctyconvert.Convert(golden.Result, result.Type())
+			if err != nil {
+				t.Fatalf("expected result %#v doesn't match the return type: %s", golden.Result, err)
+			}
+			if !expected.RawEquals(result) {
+				t.Errorf("expected %#v, got %#v", expected, result)
+			}
+		})
+	}
+}
+
+// updateGoldenFiles returns true if the golden files should be updated. The
+// -update flag is looked up rather than defined here, so test binaries that
+// define their own don't panic.
+func updateGoldenFiles() bool {
+	if update, _ := strconv.ParseBool(os.Getenv(UpdateEnvVar)); update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			update, _ := getter.Get().(bool)
+			return update
+		}
+	}
+	return false
+}
+
+// LoadGoldenCase loads a case from a .hcl or .json golden file.
+func LoadGoldenCase(path string) (*GoldenCase, error) {
+	parser := hclparse.NewParser()
+
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if filepath.Ext(path) == ".json" {
+		file, diags = parser.ParseJSONFile(path)
+	} else {
+		file, diags = parser.ParseHCLFile(path)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	attributes, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	golden := &GoldenCase{
+		Result: cty.NullVal(cty.DynamicPseudoType),
+	}
+	for name, attribute := range attributes {
+		value, diags := attribute.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		switch name {
+		case "function":
+			if value.Type() != cty.String || value.IsNull() {
+				return nil, errors.New("function must be a string")
+			}
+			golden.Function = value.AsString()
+		case "arguments":
+			if !value.CanIterateElements() || value.Type().IsMapType() || value.Type().IsObjectType() {
+				return nil, errors.New("arguments must be a list")
+			}
+			golden.Arguments = append(golden.Arguments, value.AsValueSlice()...)
+		case "result":
+			golden.Result = value
+		case "error":
+			if value.Type() != cty.String || value.IsNull() {
+				return nil, errors.New("error must be a string")
+			}
+			golden.Error = value.AsString()
+		default:
+			return nil, fmt.Errorf("unexpected attribute %q", name)
+		}
+	}
+
+	if len(golden.Function) == 0 {
+		return nil, errors.New("missing function")
+	}
+	return golden, nil
+}
+
+// writeGoldenCase replaces the expected result or error in the golden file at
+// path with the actual one.
+func writeGoldenCase(path string, result cty.Value, err error) error {
+	src, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return readErr
+	}
+
+	if filepath.Ext(path) == ".json" {
+		var golden struct {
+			Function  string          `json:"function"`
+			Arguments json.RawMessage `json:"arguments,omitempty"`
+			Result    json.RawMessage `json:"result,omitempty"`
+			Error     string          `json:"error,omitempty"`
+		}
+		if err := json.Unmarshal(src, &golden); err != nil {
+			return err
+		}
+
+		golden.Result, golden.Error = nil, ""
+		if err != nil {
+			golden.Error = err.Error()
+		} else {
+			encoded, err := ctyjson.SimpleJSONValue{Value: result}.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			golden.Result = encoded
+		}
+
+		var out bytes.Buffer
+		encoder := json.NewEncoder(&out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(golden); err != nil {
+			return err
+		}
+		return os.WriteFile(path, out.Bytes(), 0644)
+	}
+
+	file, diags := hclwrite.ParseConfig(src, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+	body := file.Body()
+	if err != nil {
+		body.RemoveAttribute("result")
+		body.SetAttributeValue("error", cty.StringVal(err.Error()))
+	} else {
+		body.RemoveAttribute("error")
+		body.SetAttributeValue("result", result)
+	}
+	return os.WriteFile(path, file.Bytes(), 0644)
+}
+
+// goldenRunner calls functions through the server the same way hosts do.
+type goldenRunner struct {
+	server    *plugins.GrpcServer
+	functions map[string]*proto.Function
+}
+
+func (r *goldenRunner) call(ctx context.Context, name string, args []cty.Value) (cty.Value, error) {
+	if r.functions == nil {
+		response, err := r.server.ListFunctions(ctx, new(proto.ListFunctionsRequest))
+		if err != nil {
+			return cty.NilVal, err
+		}
+		r.functions = make(map[string]*proto.Function)
+		for name, fn := range response.Functions {
+			r.functions[name] = fn
+			for _, alias := range fn.Aliases {
+				r.functions[alias] = fn
+			}
+		}
+	}
+
+	fn, ok := r.functions[name]
+	if !ok {
+		return cty.NilVal, fmt.Errorf("function %s not found", name)
+	}
+
+	arguments := make([][]byte, len(args))
+	types := make([][]byte, len(args))
+	for ix, arg := range args {
+		parameter := fn.VariadicParameter
+		if ix < len(fn.Parameters) {
+			parameter = fn.Parameters[ix]
+		}
+		if parameter == nil {
+			return cty.NilVal, fmt.Errorf("too many arguments for %s", name)
+		}
+
+		param, err := parameter.ToCtyParameter()
+		if err != nil {
+			return cty.NilVal, err
+		}
+		arg, err = ctyconvert.Convert(arg, param.Type)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid argument %d: %w", ix, err)
+		}
+
+		if arguments[ix], err = msgpack.Marshal(arg, param.Type); err != nil {
+			return cty.NilVal, fmt.Errorf("invalid argument %d: %w", ix, err)
+		}
+		if types[ix], err = ctyjson.MarshalType(arg.Type()); err != nil {
+			return cty.NilVal, err
+		}
+	}
+
+	returnType := fn.ReturnType
+	if fn.DynamicReturnType {
+		response, err := r.server.GetReturnType(ctx, &proto.GetReturnTypeRequest{
+			Name:          name,
+			ArgumentTypes: types,
+		})
+		if err != nil {
+			return cty.NilVal, err
+		}
+		if err := diagnosticsError(response.Diagnostics); err != nil {
+			return cty.NilVal, err
+		}
+		returnType = response.ReturnType
+	}
+
+	response, err := r.server.ExecuteFunction(ctx, &proto.ExecuteFunctionRequest{
+		Name:      name,
+		Arguments: arguments,
+	})
+	if err != nil {
+		return cty.NilVal, err
+	}
+	if err := diagnosticsError(response.Diagnostics); err != nil {
+		return cty.NilVal, err
+	}
+
+	ty, err := ctyjson.UnmarshalType(returnType)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return msgpack.Unmarshal(response.Result, ty)
+}
+
+// diagnosticsError joins any error diagnostics into a single error.
+func diagnosticsError(diags []*proto.Diagnostic) error {
+	var errs []error
+	for _, diag := range diags {
+		if diag.Severity == proto.Diagnostic_ERROR {
+			errs = append(errs, fmt.Errorf("%s: %s", diag.Summary, diag.Detail))
+		}
+	}
+	return errors.Join(errs...)
+}
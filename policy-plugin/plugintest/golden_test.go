@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugintest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunGoldenFiles(t *testing.T) {
+	RegisterConformanceFunctions()
+	RunGoldenFiles(t, filepath.Join("testdata", "golden"))
+}
+
+func TestRunGoldenFiles_Update(t *testing.T) {
+	RegisterConformanceFunctions()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"join.hcl":  "function  = \"conformance::join\"\narguments = [\"-\", \"a\", \"b\"]\nresult    = \"outdated\"\n",
+		"fail.hcl":  "function  = \"conformance::fail\"\narguments = [\"broken\"]\nresult    = \"outdated\"\n",
+		"echo.json": `{"function": "conformance::echo", "arguments": ["hello"], "error": "outdated"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv(UpdateEnvVar, "1")
+	RunGoldenFiles(t, dir)
+
+	expected := map[string]string{
+		"join.hcl":  `result    = "a-b"`,
+		"fail.hcl":  `error     = "Error in function call: broken"`,
+		"echo.json": `"result": "hello"`,
+	}
+	for name, want := range expected {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), want) || strings.Contains(string(content), "outdated") {
+			t.Errorf("expected %s to be updated with %s, got:\n%s", name, want, content)
+		}
+	}
+
+	// The updated files pass without updating.
+	t.Setenv(UpdateEnvVar, "")
+	RunGoldenFiles(t, dir)
+}
+
+// update is defined the way golden tests commonly define it, which must not
+// conflict with plugintest.
+var update = flag.Bool("update", false, "update golden files")
+
+func TestUpdateGoldenFiles(t *testing.T) {
+	t.Setenv(UpdateEnvVar, "")
+	if updateGoldenFiles() {
+		t.Errorf("expected golden files not to be updated by default")
+	}
+
+	*update = true
+	defer func() {
+		*update = false
+	}()
+	if !updateGoldenFiles() {
+		t.Errorf("expected the -update flag of the test binary to update golden files")
+	}
+}
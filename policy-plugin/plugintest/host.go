@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package plugintest provides helpers for testing plugin functions without a
+// real policy runtime.
+package plugintest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+)
+
+var (
+	_ plugins.FunctionHost = (*FakeHost)(nil)
+)
+
+// FakeHost is a plugins.FunctionHost that serves fixed resources, variables
+// and functions, and records the messages functions log. Pass it to
+// plugins.ContextWithHost to test functions with plugins.CallFunctionContext,
+// or to client.WithHost to serve it to a plugin process.
+type FakeHost struct {
+	// Resources maps resource addresses to their values.
+	Resources map[string]cty.Value
+
+	// Variables maps variable names to their values.
+	Variables map[string]cty.Value
+
+	// Functions maps names to the functions the host provides to plugins.
+	Functions map[string]function.Function
+
+	mutex sync.Mutex
+	logs  []LogEntry
+}
+
+// LogEntry is a message logged to a FakeHost.
+type LogEntry struct {
+	Level   hclog.Level
+	Message string
+
+	// Call identifies the function call that logged the message, if known.
+	Call plugins.Call
+}
+
+func (h *FakeHost) GetResource(_ context.Context, address string) (cty.Value, bool, error) {
+	value, ok := h.Resources[address]
+	return value, ok, nil
+}
+
+func (h *FakeHost) GetVariable(_ context.Context, name string) (cty.Value, bool, error) {
+	value, ok := h.Variables[name]
+	return value, ok, nil
+}
+
+func (h *FakeHost) CallFunction(_ context.Context, name string, args ...cty.Value) (cty.Value, error) {
+	fn, ok := h.Functions[name]
+	if !ok {
+		return cty.NilVal, fmt.Errorf("function %s not found", name)
+	}
+	return fn.Call(args)
+}
+
+func (h *FakeHost) Log(ctx context.Context, level hclog.Level, message string) error {
+	call, _ := plugins.CallFromContext(ctx)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.logs = append(h.logs, LogEntry{
+		Level:   level,
+		Message: message,
+		Call:    call,
+	})
+	return nil
+}
+
+// Logs returns the messages logged so far.
+func (h *FakeHost) Logs() []LogEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return append([]LogEntry(nil), h.logs...)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugintest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-hclog"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform-policy-plugin-framework/policy-plugin/plugins"
+)
+
+func TestFakeHost(t *testing.T) {
+	plugins.RegisterFunction("bucket_region", func(ctx context.Context, address string) (string, error) {
+		host := plugins.HostFromContext(ctx)
+
+		resource, ok, err := host.GetResource(ctx, address)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("no resource at %s", address)
+		}
+
+		if err := host.Log(ctx, hclog.Info, "found "+address); err != nil {
+			return "", err
+		}
+		return resource.GetAttr("region").AsString(), nil
+	})
+
+	host := &FakeHost{
+		Resources: map[string]cty.Value{
+			"aws_s3_bucket.logs": cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("eu-west-1"),
+			}),
+		},
+	}
+	ctx := plugins.ContextWithHost(context.Background(), host)
+
+	result, err := plugins.CallFunctionContext(ctx, "bucket_region", cty.StringVal("aws_s3_bucket.logs"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.RawEquals(cty.StringVal("eu-west-1")) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+
+	if diff := cmp.Diff([]LogEntry{{Level: hclog.Info, Message: "found aws_s3_bucket.logs"}}, host.Logs()); diff != "" {
+		t.Errorf("unexpected logs (-want +got):\n%s", diff)
+	}
+
+	if _, err := plugins.CallFunctionContext(ctx, "bucket_region", cty.StringVal("aws_s3_bucket.missing")); err == nil {
+		t.Errorf("expected an error for a missing resource")
+	}
+
+	// Without a host, functions get ErrNoHost.
+	if _, err := plugins.CallFunctionContext(context.Background(), "bucket_region", cty.StringVal("aws_s3_bucket.logs")); !errors.Is(err, plugins.ErrNoHost) {
+		t.Errorf("expected ErrNoHost, got %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+function  = "conformance::fail"
+arguments = ["failed on purpose"]
+error     = "failed on purpose"
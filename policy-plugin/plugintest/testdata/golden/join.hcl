@@ -0,0 +1,3 @@
+function  = "conformance::join"
+arguments = [", ", "a", "b"]
+result    = "a, b"
@@ -0,0 +1,3 @@
+function  = "conformance::optional"
+arguments = [null]
+result    = "<null>"
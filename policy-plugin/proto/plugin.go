@@ -4,6 +4,9 @@
 package proto
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 	ctyjson "github.com/zclconf/go-cty/cty/json"
@@ -79,3 +82,66 @@ func FromCtyFunction(fn function.Function) (*Function, error) {
 		Description:       fn.Description(),
 	}, nil
 }
+
+// ToCtyFunction converts the function definition back into a cty function that
+// is implemented by impl.
+func (fn *Function) ToCtyFunction(impl function.ImplFunc) (function.Function, error) {
+	returnType, err := ctyjson.UnmarshalType(fn.ReturnType)
+	if err != nil {
+		return function.Function{}, err
+	}
+	return fn.ToCtyFunctionWithReturnType(function.StaticReturnType(returnType), impl)
+}
+
+// ToCtyFunctionWithReturnType converts the function definition back into a cty
+// function that is implemented by impl, with the return type computed by
+// returnType. This is needed for functions with a dynamic return type.
+func (fn *Function) ToCtyFunctionWithReturnType(returnType function.TypeFunc, impl function.ImplFunc) (function.Function, error) {
+	var parameters []function.Parameter
+	for _, parameter := range fn.Parameters {
+		param, err := parameter.ToCtyParameter()
+		if err != nil {
+			return function.Function{}, err
+		}
+		parameters = append(parameters, param)
+	}
+
+	var variadic *function.Parameter
+	if fn.VariadicParameter != nil {
+		param, err := fn.VariadicParameter.ToCtyParameter()
+		if err != nil {
+			return function.Function{}, err
+		}
+		variadic = &param
+	}
+
+	return function.New(&function.Spec{
+		Description: fn.Description,
+		Params:      parameters,
+		VarParam:    variadic,
+		Type:        returnType,
+		Impl:        impl,
+	}), nil
+}
+
+// Diagnostic returns the warning for calls to the named function deprecated as
+// described by deprecation.
+func (deprecation *FunctionDeprecation) Diagnostic(name string) *Diagnostic {
+	detail := fmt.Sprintf("The function %q is deprecated", name)
+	if len(deprecation.Message) > 0 {
+		detail += ": " + strings.TrimSuffix(deprecation.Message, ".")
+	}
+	detail += "."
+	if len(deprecation.Replacement) > 0 {
+		detail += fmt.Sprintf(" Use %q instead.", deprecation.Replacement)
+	}
+	if len(deprecation.RemovalVersion) > 0 {
+		detail += fmt.Sprintf(" It will be removed in version %s.", deprecation.RemovalVersion)
+	}
+
+	return &Diagnostic{
+		Severity: Diagnostic_WARNING,
+		Summary:  "Deprecated function",
+		Detail:   detail,
+	}
+}
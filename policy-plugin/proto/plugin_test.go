@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+func TestFunctionRoundTrip(t *testing.T) {
+	fn := function.New(&function.Spec{
+		Description: "Merges tags.",
+		Params: []function.Parameter{
+			{
+				Name:        "tags",
+				Description: "The tags to merge.",
+				Type:        cty.Map(cty.String),
+				AllowNull:   true,
+			},
+			{
+				Name:             "defaults",
+				Type:             cty.Object(map[string]cty.Type{"owner": cty.String}),
+				AllowUnknown:     true,
+				AllowDynamicType: true,
+				AllowMarked:      true,
+			},
+		},
+		VarParam: &function.Parameter{
+			Name: "extra",
+			Type: cty.List(cty.Number),
+		},
+		Type: function.StaticReturnType(cty.Map(cty.String)),
+		Impl: func([]cty.Value, cty.Type) (cty.Value, error) {
+			return cty.MapValEmpty(cty.String), nil
+		},
+	})
+
+	described, err := FromCtyFunction(fn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	converted, err := described.ToCtyFunction(func([]cty.Value, cty.Type) (cty.Value, error) {
+		return cty.MapValEmpty(cty.String), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if converted.Description() != fn.Description() {
+		t.Errorf("expected description %q, got %q", fn.Description(), converted.Description())
+	}
+	for ix, param := range fn.Params() {
+		actual := converted.Params()[ix]
+		if actual.Name != param.Name || actual.Description != param.Description || !actual.Type.Equals(param.Type) ||
+			actual.AllowNull != param.AllowNull || actual.AllowUnknown != param.AllowUnknown ||
+			actual.AllowDynamicType != param.AllowDynamicType || actual.AllowMarked != param.AllowMarked {
+			t.Errorf("parameter %d changed in the round trip: expected %#v, got %#v", ix, param, actual)
+		}
+	}
+	if variadic := converted.VarParam(); variadic == nil || variadic.Name != "extra" || !variadic.Type.Equals(cty.List(cty.Number)) {
+		t.Errorf("unexpected variadic parameter: %#v", variadic)
+	}
+
+	returnType, err := converted.ReturnType([]cty.Type{cty.Map(cty.String), cty.Object(map[string]cty.Type{"owner": cty.String})})
+	if err != nil || !returnType.Equals(cty.Map(cty.String)) {
+		t.Errorf("unexpected return type %#v (%v)", returnType, err)
+	}
+}